@@ -0,0 +1,256 @@
+package bamcp
+
+import (
+	"rand"
+	"strconv"
+	"strings"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlbayes"
+	"github.com/skelterjohn/rlalg/bfs3"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/uct"
+)
+
+type Config struct {
+	//simulations per decision
+	NumSimulations uint64
+	Depth          uint64
+	//nanoseconds per decision. If set, simulations run until it has passed and
+	//NumSimulations is ignored.
+	Deadline int64
+	UCT      uct.Config
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.NumSimulations = 1000
+	cfg.Depth = 15
+	cfg.Deadline = 0
+	cfg.UCT = uct.ConfigDefault()
+	return
+}
+
+//a history of steps, real and simulated, from where the agent's episode began.
+//Histories that took the same actions to the same states are equal.
+type history struct {
+	parent   *history
+	a        discrete.Action
+	s        discrete.State
+	terminal bool
+	depth    uint64
+	hash     uint64
+}
+
+func (h *history) child(a discrete.Action, s discrete.State, terminal bool) (c *history) {
+	c = &history{parent: h, a: a, s: s, terminal: terminal, depth: h.depth + 1}
+	key := a.Hashcode()<<32 ^ s.Hashcode()
+	if terminal {
+		key = ^key
+	}
+	c.hash = (h.hash ^ key) * 0x9e3779b97f4a7c15
+	return
+}
+func (h *history) lessThan(other *history) bool {
+	if h.depth != other.depth {
+		return h.depth < other.depth
+	}
+	for x, y := h, other; x != y; x, y = x.parent, y.parent {
+		if x.a != y.a {
+			return x.a < y.a
+		}
+		if x.s != y.s {
+			return x.s < y.s
+		}
+		if x.terminal != y.terminal {
+			return y.terminal
+		}
+	}
+	return false
+}
+
+//an MDP drawn from the root belief one (s,a) at a time, as the simulation needs
+//it, from rng. Plan draws a new one for every simulation.
+type lazyMDP struct {
+	sampler    bfs3.TransitionSampler
+	numActions uint64
+	ps         map[uint64][]float64
+	rng        *rand.Rand
+}
+
+func (m *lazyMDP) next(s discrete.State, a discrete.Action, u float64) (n discrete.State, r float64, terminal bool) {
+	key := s.Hashcode()*m.numActions + a.Hashcode()
+	ps, ok := m.ps[key]
+	if !ok {
+		ps = m.sampler.SampleTransition(s, a, m.rng)
+		m.ps[key] = ps
+	}
+	r = m.sampler.MeanR(s, a)
+	for i, p := range ps {
+		if u < p {
+			n = discrete.State(i)
+			return
+		}
+		u -= p
+	}
+	terminal = true
+	return
+}
+
+//historyOracle is the search state: the tree is over histories, and the steps
+//are drawn from the MDP of the simulation running now.
+type historyOracle struct {
+	m *lazyMDP
+	h *history
+}
+
+func (this historyOracle) Hashcode() uint64 {
+	return this.h.hash
+}
+func (this historyOracle) LessThan(other interface{}) bool {
+	return this.h.lessThan(other.(historyOracle).h)
+}
+func (this historyOracle) Terminal() bool {
+	return this.h.terminal
+}
+func (this historyOracle) Next(action uint64) (o uct.Oracle, r float64) {
+	return this.step(discrete.Action(action), rand.Float64())
+}
+//NextFrom makes historyOracle a uct.SourcedOracle, so the searcher's seed
+//decides the simulated steps.
+func (this historyOracle) NextFrom(action uint64, rng *rand.Rand) (o uct.Oracle, r float64) {
+	return this.step(discrete.Action(action), rng.Float64())
+}
+func (this historyOracle) step(a discrete.Action, u float64) (o uct.Oracle, r float64) {
+	n, r, terminal := this.m.next(this.h.s, a, u)
+	o = historyOracle{this.m, this.h.child(a, n, terminal)}
+	return
+}
+
+//Agent is Bayes-adaptive Monte Carlo planning (Guez, Silver and Dayan): UCT
+//over histories, where each simulation runs in an MDP sampled from the root
+//belief, and only the (s,a)s the simulation visits are sampled. The search is a
+//uct.Searcher, which always memoizes, so that a history's node is found again.
+//The belief from the prior must implement bfs3.TransitionSampler.
+type Agent struct {
+	task       *rlglue.TaskSpec
+	prior      bfs3.Prior
+	belief     bayes.BeliefState
+	s          *uct.Searcher
+	m          *lazyMDP
+	root       *history
+	lastAction discrete.Action
+	steps      uint64
+	Cfg        Config
+	LastPlan   plan.Stats
+	Telemetry  telemetry.Sink
+	rng        *rand.Rand
+}
+
+func New(cfg Config, prior bfs3.Prior) (this *Agent) {
+	this = new(Agent)
+	this.Cfg = cfg
+	this.prior = prior
+	this.s = uct.New()
+	this.s.Cfg = cfg.UCT
+	this.s.Cfg.Memoize = true
+	if err := this.s.Cfg.Check(); err != nil {
+		panic(err.String())
+	}
+	this.Telemetry = telemetry.Nop{}
+	this.Seed(cfg.UCT.Seed)
+	return
+}
+//Seed reseeds the MDP draws, and the searcher with a seed drawn from them.
+func (this *Agent) Seed(seed int64) {
+	this.rng = rand.New(rand.NewSource(seed))
+	this.s.Seed(this.rng.Int63())
+	if this.m != nil {
+		this.m.rng = this.rng
+	}
+}
+func (this *Agent) GetBelief() bayes.BeliefState {
+	return this.belief
+}
+func (this *Agent) AgentInit(taskString string) {
+	this.task, _ = rlglue.ParseTaskSpec(taskString)
+	if this.s.Cfg.Gamma == 0 {
+		this.s.Cfg.Gamma = this.task.DiscountFactor
+		if this.s.Cfg.Gamma == 1 {
+			this.s.Cfg.Gamma = 0.95
+		}
+	}
+	this.s.NumActions = this.task.Act.Ints.Count()
+	if this.s.Cfg.ValueRange == 0 && this.s.Cfg.Gamma < 1 {
+		this.s.Cfg.ValueRange = (this.task.Reward.Max - this.task.Reward.Min) / (1 - this.s.Cfg.Gamma)
+	}
+	this.belief = this.prior(this.task)
+	sampler, ok := this.belief.(bfs3.TransitionSampler)
+	if !ok {
+		panic("bamcp: the prior's belief cannot sample transitions")
+	}
+	this.m = &lazyMDP{sampler: sampler, numActions: this.s.NumActions, rng: this.rng}
+}
+func (this *Agent) rootNode() *uct.Node {
+	return this.s.GetNode(historyOracle{this.m, this.root})
+}
+//Plan runs simulations from the current belief, each in a new sample of the
+//MDP.
+func (this *Agent) Plan() {
+	this.s.Telemetry = this.Telemetry
+	this.m.sampler = this.belief.(bfs3.TransitionSampler)
+	root := this.rootNode()
+	var lim plan.Limits
+	if this.Cfg.Deadline != 0 {
+		lim.Deadline = this.Cfg.Deadline
+	} else {
+		lim.Trajectories = this.Cfg.NumSimulations
+	}
+	this.LastPlan = plan.Run(lim, func() uint64 {
+		this.m.ps = make(map[uint64][]float64)
+		return this.s.RunTrajectory(root, this.Cfg.Depth)
+	}, nil)
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlanningFinished, Trajectories: this.LastPlan.Trajectories, Expanded: this.LastPlan.Expanded, Elapsed: this.LastPlan.Elapsed, Reason: this.LastPlan.Stop.String()})
+}
+func (this *Agent) getAction() (act rlglue.Action) {
+	this.Plan()
+	root := this.rootNode()
+	this.lastAction = discrete.Action(this.s.GetAction(root))
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: this.steps, Action: this.lastAction.Hashcode(), Q: this.s.GetQs(root)})
+	act = rlglue.NewAction(this.task.Act.Ints.Values(this.lastAction.Hashcode()), []float64{}, []byte{})
+	return
+}
+func (this *Agent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	if s != this.belief.GetState() {
+		this.belief.Teleport(s)
+	}
+	this.s.Forget()
+	this.root = &history{s: s}
+	this.steps = 0
+	act = this.getAction()
+	return
+}
+func (this *Agent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, s, false, reward)
+	//the subtree for what really happened is still a valid search from here
+	this.root = this.root.child(this.lastAction, s, false)
+	this.steps++
+	act = this.getAction()
+	return
+}
+func (this *Agent) AgentEnd(reward float64) {
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, 0, true, reward)
+	this.root = nil
+}
+func (this *Agent) AgentCleanup() {
+}
+func (this *Agent) AgentMessage(message string) string {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		this.Seed(seed)
+	}
+	return ""
+}
@@ -1,8 +1,12 @@
 package beb
 
 import (
+	"fmt"
+	"math"
+	"os"
 	"go-glue.googlecode.com/hg/rlglue"
 	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/telemetry"
 	"github.com/skelterjohn/rlalg/vi"
 )
 
@@ -10,86 +14,219 @@ type RewardFunc func(s discrete.State, a discrete.Action) (r float64)
 type BebConfig struct {
 	Beta	float64
 	Epsilon	float64
+	Alpha	float64
+	Bonus	string
 	RFoo	RewardFunc
 }
 
 func BebConfigDefault() (cfg BebConfig) {
 	cfg.Beta = 1
 	cfg.Epsilon = .1
+	cfg.Alpha = 1
+	cfg.Bonus = "inverse"
 	cfg.RFoo = nil
 	return
 }
 
-type BebMDP struct {
-	discrete.FlatMDP
+//Check reports settings the agent cannot run with.
+func (cfg BebConfig) Check() (err os.Error) {
+	if _, ok := Bonuses[cfg.Bonus]; !ok && cfg.Bonus != "" {
+		err = fmt.Errorf("beb: unknown bonus %q", cfg.Bonus)
+	}
+	return
+}
+
+//Posterior is a Dirichlet posterior over each (s,a)'s next-state distribution,
+//with Alpha pseudo-counts on every next state, and the observed rewards.
+type Posterior struct {
+	Alpha		float64
 	CountsSA	[][]int
 	CountsSAN	[][][]int
 	TotalR		[][]float64
-	Beta		float64
-	RFoo		RewardFunc
+	TotalR2		[][]float64
 }
 
-func NewBebMDP(task *rlglue.TaskSpec, Cfg BebConfig) (this *BebMDP) {
-	numStates := task.Obs.Ints.Count()
-	numActions := task.Act.Ints.Count()
-	this = new(BebMDP)
-	this.Task = task
-	this.Transitions = make([][][]float64, numStates)
-	this.Rewards = make([][]float64, numStates)
-	this.Gamma = task.DiscountFactor
+func NewPosterior(numStates, numActions uint64, alpha float64) (this *Posterior) {
+	this = new(Posterior)
+	this.Alpha = alpha
 	this.CountsSA = make([][]int, numStates)
 	this.CountsSAN = make([][][]int, numStates)
 	this.TotalR = make([][]float64, numStates)
+	this.TotalR2 = make([][]float64, numStates)
 	for s := range this.CountsSA {
 		this.CountsSA[s] = make([]int, numActions)
 		this.CountsSAN[s] = make([][]int, numActions)
-		for a, _ := range this.CountsSAN[s] {
+		for a := range this.CountsSAN[s] {
 			this.CountsSAN[s][a] = make([]int, numStates)
 		}
 		this.TotalR[s] = make([]float64, numActions)
+		this.TotalR2[s] = make([]float64, numActions)
+	}
+	return
+}
+func (this *Posterior) Count(s discrete.State, a discrete.Action) float64 {
+	return float64(this.CountsSA[s][a])
+}
+//the sum of the posterior's Dirichlet parameters for (s,a)
+func (this *Posterior) Alpha0(s discrete.State, a discrete.Action) float64 {
+	return this.Alpha*float64(len(this.CountsSAN[s][a])) + this.Count(s, a)
+}
+//posterior mean of T(s,a,n). Terminal observations count toward (s,a) but not
+//toward any n, so the leftover mass is the chance of termination.
+func (this *Posterior) Mean(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	alpha0 := this.Alpha0(s, a)
+	if alpha0 == 0 {
+		return 0
+	}
+	return (this.Alpha + float64(this.CountsSAN[s][a][n])) / alpha0
+}
+//posterior variance of T(s,a,n)
+func (this *Posterior) Var(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	alpha0 := this.Alpha0(s, a)
+	if alpha0 == 0 {
+		return 0
+	}
+	alphan := this.Alpha + float64(this.CountsSAN[s][a][n])
+	return alphan * (alpha0 - alphan) / (alpha0 * alpha0 * (alpha0 + 1))
+}
+//empirical mean reward for (s,a), 0 if it has never been tried
+func (this *Posterior) MeanR(s discrete.State, a discrete.Action) float64 {
+	if this.CountsSA[s][a] == 0 {
+		return 0
+	}
+	return this.TotalR[s][a] / this.Count(s, a)
+}
+//sample variance of the rewards seen for (s,a), 0 if it has been tried fewer than twice
+func (this *Posterior) VarR(s discrete.State, a discrete.Action) float64 {
+	n := this.Count(s, a)
+	if n < 2 {
+		return 0
+	}
+	mean := this.TotalR[s][a] / n
+	return (this.TotalR2[s][a] - n*mean*mean) / (n - 1)
+}
+func (this *Posterior) Observe(s discrete.State, a discrete.Action, n discrete.State, r float64) {
+	this.CountsSA[s][a]++
+	this.CountsSAN[s][a][n]++
+	this.TotalR[s][a] += r
+	this.TotalR2[s][a] += r * r
+}
+func (this *Posterior) ObserveTerminal(s discrete.State, a discrete.Action, r float64) {
+	this.CountsSA[s][a]++
+	this.TotalR[s][a] += r
+	this.TotalR2[s][a] += r * r
+}
+
+type BonusFunc func(mdp *BebMDP, s discrete.State, a discrete.Action) (bonus float64)
+
+//beta/(1+n), as in Kolter and Ng
+func InverseBonus(mdp *BebMDP, s discrete.State, a discrete.Action) (bonus float64) {
+	return mdp.Beta / (1 + mdp.Count(s, a))
+}
+//beta/sqrt(n), with untried (s,a)s getting the full beta
+func SqrtBonus(mdp *BebMDP, s discrete.State, a discrete.Action) (bonus float64) {
+	n := mdp.Count(s, a)
+	if n < 1 {
+		n = 1
+	}
+	return mdp.Beta / math.Sqrt(n)
+}
+//beta times the posterior standard deviation of the next-state distribution
+func VarianceBonus(mdp *BebMDP, s discrete.State, a discrete.Action) (bonus float64) {
+	if mdp.Alpha0(s, a) == 0 {
+		return mdp.Beta
+	}
+	var v float64
+	for n := range mdp.CountsSAN[s][a] {
+		v += mdp.Var(s, a, discrete.State(n))
+	}
+	return mdp.Beta * math.Sqrt(v)
+}
+
+var Bonuses = map[string]BonusFunc{
+	"inverse":	InverseBonus,
+	"sqrt":		SqrtBonus,
+	"variance":	VarianceBonus,
+}
+
+type BebMDP struct {
+	discrete.FlatMDP
+	*Posterior
+	Beta	float64
+	Bonus	BonusFunc
+	RFoo	RewardFunc
+}
+
+func NewBebMDP(task *rlglue.TaskSpec, Cfg BebConfig) (this *BebMDP) {
+	numStates := task.Obs.Ints.Count()
+	numActions := task.Act.Ints.Count()
+	this = new(BebMDP)
+	this.Task = task
+	this.Transitions = make([][][]float64, numStates)
+	this.Rewards = make([][]float64, numStates)
+	this.Gamma = task.DiscountFactor
+	this.Posterior = NewPosterior(numStates, numActions, Cfg.Alpha)
+	for s := range this.Transitions {
 		this.Rewards[s] = make([]float64, numActions)
 		this.Transitions[s] = make([][]float64, numActions)
-		for a, _ := range this.Transitions[s] {
+		for a := range this.Transitions[s] {
 			this.Transitions[s][a] = make([]float64, numStates)
+			this.resolve(discrete.State(s), discrete.Action(a))
 		}
 	}
 	this.Beta = Cfg.Beta
+	if err := Cfg.Check(); err != nil {
+		panic(err.String())
+	}
+	this.Bonus = InverseBonus
+	if Cfg.Bonus != "" {
+		this.Bonus = Bonuses[Cfg.Bonus]
+	}
+	this.RFoo = Cfg.RFoo
 	return
 }
 func (rm *BebMDP) T(s discrete.State, a discrete.Action, n discrete.State) float64 {
 	return rm.Transitions[s][a][n]
 }
-func (this *BebMDP) R(s discrete.State, a discrete.Action) float64 {
-	n := float64(this.CountsSA[s][a])
-	bonus := this.Beta / (1 + n)
-	r := this.Rewards[s][a]
+//the reward without any bonus: RFoo if there is one, the learned mean otherwise
+func (this *BebMDP) BaseR(s discrete.State, a discrete.Action) float64 {
 	if this.RFoo != nil {
-		r = this.RFoo(s, a)
+		return this.RFoo(s, a)
 	}
-	return r + bonus
+	return this.Rewards[s][a]
 }
-func (rm *BebMDP) resolve(s discrete.State, a discrete.Action) {
-	if rm.Transitions[s] == nil {
-		rm.Transitions[s] = make([][]float64, len(rm.CountsSAN[s]))
+//RewardRange bounds BaseR: the task's reward range for learned rewards, or the
+//least and greatest RFoo gives over every state and action.
+func (this *BebMDP) RewardRange() (min, max float64) {
+	if this.RFoo == nil {
+		return this.Task.Reward.Min, this.Task.Reward.Max
 	}
-	if rm.Transitions[s][a] == nil {
-		rm.Transitions[s][a] = make([]float64, len(rm.CountsSAN[s][a]))
+	min, max = math.Inf(1), math.Inf(-1)
+	for s := range this.Rewards {
+		for a := range this.Rewards[s] {
+			r := this.RFoo(discrete.State(s), discrete.Action(a))
+			min, max = math.Fmin(min, r), math.Fmax(max, r)
+		}
 	}
-	norm := 1.0 / float64(rm.CountsSA[s][a])
-	for n, count := range rm.CountsSAN[s][a] {
-		p := float64(count) * norm
-		rm.Transitions[s][a][n] = p
+	return
+}
+func (this *BebMDP) R(s discrete.State, a discrete.Action) float64 {
+	return this.BaseR(s, a) + this.Bonus(this, s, a)
+}
+func (rm *BebMDP) resolve(s discrete.State, a discrete.Action) {
+	for n := range rm.Transitions[s][a] {
+		rm.Transitions[s][a][n] = rm.Mean(s, a, discrete.State(n))
 	}
-	rm.Rewards[s][a] = rm.TotalR[s][a] * norm
+	rm.Rewards[s][a] = rm.MeanR(s, a)
 }
 func (rm *BebMDP) Observe(s discrete.State, a discrete.Action, n discrete.State, r float64) (learned bool) {
-	rm.CountsSA[s][a]++
-	rm.CountsSAN[s][a][n]++
+	rm.Posterior.Observe(s, a, n, r)
 	rm.resolve(s, a)
 	return true
 }
 func (rm *BebMDP) ObserveTerminal(s discrete.State, a discrete.Action, r float64) (learned bool) {
-	rm.CountsSA[s][a]++
+	rm.Posterior.ObserveTerminal(s, a, r)
+	rm.resolve(s, a)
 	return true
 }
 
@@ -100,12 +237,14 @@ type BebAgent struct {
 	lastState	discrete.State
 	lastAction	discrete.Action
 	Cfg		BebConfig
-	GetRFoo		func(task *rlglue.TaskSpec) (foo RewardFunc)
+	GetRFoo		RewardGetter
+	Telemetry	telemetry.Sink
 }
 
-func NewBebAgent(Cfg BebConfig, GetRFoo func(task *rlglue.TaskSpec) (foo RewardFunc)) (ra *BebAgent) {
+func NewBebAgent(Cfg BebConfig, GetRFoo RewardGetter) (ra *BebAgent) {
 	ra = new(BebAgent)
 	ra.Cfg = Cfg
+	ra.Telemetry = telemetry.Nop{}
 	ra.GetRFoo = GetRFoo
 	return
 }
@@ -114,14 +253,16 @@ func (ra *BebAgent) AgentInit(taskString string) {
 	if ra.task.DiscountFactor == 1 {
 		ra.task.DiscountFactor = 0.99
 	}
+	if ra.GetRFoo != nil {
+		ra.Cfg.RFoo = BuildReward(ra.GetRFoo, ra.task)
+	}
 	ra.rmdp = NewBebMDP(ra.task, ra.Cfg)
 	ra.qt = discrete.NewQTable(ra.task.Obs.Ints.Count(), ra.task.Act.Ints.Count())
-	ra.Cfg.RFoo = ra.GetRFoo(ra.task)
-	ra.rmdp.RFoo = ra.Cfg.RFoo
+	vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
 }
 func (ra *BebAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
 	ra.lastState = discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
-	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.qt.Pi(ra.lastState).Hashcode()), []float64{}, []byte{})
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
 	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
 	return
 }
@@ -132,7 +273,7 @@ func (ra *BebAgent) AgentStep(reward float64, obs rlglue.Observation) (act rlglu
 		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
 	}
 	ra.lastState = nextState
-	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.qt.Pi(ra.lastState).Hashcode()), []float64{}, []byte{})
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
 	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
 	return
 }
@@ -142,6 +283,15 @@ func (ra *BebAgent) AgentEnd(reward float64) {
 		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
 	}
 }
+func (ra *BebAgent) chooseAction() (action discrete.Action) {
+	action = ra.qt.Pi(ra.lastState)
+	q := make([]float64, ra.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = ra.qt.Q(ra.lastState, discrete.Action(a))
+	}
+	ra.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: action.Hashcode(), Q: q})
+	return
+}
 func (ra *BebAgent) AgentCleanup() {
 }
 func (ra *BebAgent) AgentMessage(message string) string {
@@ -0,0 +1,147 @@
+package bebfs3
+
+import (
+	"os"
+	"strings"
+	"strconv"
+	"rand"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/beb"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/fsss/fsssmdp"
+	"github.com/skelterjohn/rlalg/telemetry"
+)
+
+type Config struct {
+	BEB		beb.BebConfig
+	Depth		uint64
+	NumTrajectories	uint64
+	Budget		uint64
+	FS3		fsss.Config
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.BEB = beb.BebConfigDefault()
+	cfg.Depth = 10
+	cfg.NumTrajectories = 500
+	cfg.Budget = 0
+	cfg.FS3 = fsss.ConfigDefault()
+	return
+}
+
+//Check reports settings the agent cannot run with.
+func (cfg Config) Check() (err os.Error) {
+	if err = cfg.BEB.Check(); err != nil {
+		return
+	}
+	return cfg.FS3.Check()
+}
+
+//BebFSSSAgent acts on the BEB model, bonus included, by planning from the
+//current state with FSSS instead of solving the whole model with value iteration.
+type BebFSSSAgent struct {
+	task			*rlglue.TaskSpec
+	rmdp			*beb.BebMDP
+	lastState		discrete.State
+	lastAction		discrete.Action
+	Cfg			Config
+	GetRFoo			beb.RewardGetter
+	s			*fsss.Searcher
+	mdpo			*fsssmdp.Oracle
+	root			*fsss.Node
+	//seeds the searcher
+	rng			*rand.Rand
+	stepsWithPlanner	uint64
+	Telemetry		telemetry.Sink
+}
+
+func New(cfg Config, GetRFoo beb.RewardGetter) (ra *BebFSSSAgent) {
+	ra = new(BebFSSSAgent)
+	ra.Cfg = cfg
+	ra.GetRFoo = GetRFoo
+	ra.Telemetry = telemetry.Nop{}
+	ra.rng = rand.New(rand.NewSource(cfg.FS3.Seed))
+	return
+}
+func (ra *BebFSSSAgent) AgentInit(taskString string) {
+	ra.task, _ = rlglue.ParseTaskSpec(taskString)
+	if ra.task.DiscountFactor == 1 {
+		ra.task.DiscountFactor = 0.99
+	}
+	if ra.GetRFoo != nil {
+		ra.Cfg.BEB.RFoo = beb.BuildReward(ra.GetRFoo, ra.task)
+	}
+	ra.rmdp = beb.NewBebMDP(ra.task, ra.Cfg.BEB)
+	ra.mdpo = fsssmdp.NewOracle(ra.rmdp, 0)
+	ra.s = fsss.New()
+	ra.s.Cfg = ra.Cfg.FS3
+	ra.s.Seed(ra.rng.Int63())
+	ra.s.NumActions = ra.rmdp.NumActions()
+	ra.s.Gamma = ra.rmdp.GetGamma()
+	//every bonus is at most beta
+	rmin, rmax := ra.rmdp.RewardRange()
+	ra.s.Vmin = rmin / (1 - ra.s.Gamma)
+	ra.s.Vmax = (rmax + ra.rmdp.Beta) / (1 - ra.s.Gamma)
+}
+func (ra *BebFSSSAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	ra.stepsWithPlanner = 0
+	ra.lastState = discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	ra.root = nil
+	ra.Plan()
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.GetAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *BebFSSSAgent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	ra.stepsWithPlanner++
+	nextState := discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	ra.rmdp.Observe(ra.lastState, ra.lastAction, nextState, reward)
+	ra.lastState = nextState
+	//the tree below was planned before this observation moved the model at the
+	//pair just taken, and its values are reused as they are
+	ra.mdpo = ra.mdpo.Teleport(ra.lastState)
+	ra.root = ra.s.Reroot(ra.root, ra.lastAction, ra.mdpo)
+	ra.Plan()
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.GetAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *BebFSSSAgent) AgentEnd(reward float64) {
+	ra.rmdp.ObserveTerminal(ra.lastState, ra.lastAction, reward)
+}
+func (ra *BebFSSSAgent) AgentCleanup() {
+}
+func (ra *BebFSSSAgent) AgentMessage(message string) string {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		ra.rng = rand.New(rand.NewSource(seed))
+		if ra.s != nil {
+			ra.s.Seed(ra.rng.Int63())
+		}
+	}
+	return ""
+}
+func (ra *BebFSSSAgent) GetAction() (action discrete.Action) {
+	action = discrete.Action(ra.s.GetAction(ra.root))
+	ra.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: ra.stepsWithPlanner, Action: action.Hashcode(), Q: ra.s.GetQs(ra.root)})
+	return
+}
+func (ra *BebFSSSAgent) Plan() {
+	ra.s.Telemetry = ra.Telemetry
+	if ra.root == nil {
+		ra.mdpo = ra.mdpo.Teleport(ra.lastState)
+		ra.root = ra.s.GetNode(ra.stepsWithPlanner, ra.mdpo)
+		ra.s.SetRoot(ra.root)
+	}
+	var expanded uint64
+	for i := 0; i < int(ra.Cfg.NumTrajectories); i++ {
+		expanded += ra.s.RunTrajectory(ra.root, ra.Cfg.Depth)
+		//Plan would evict between trajectories, so do it here too
+		ra.s.Evict(ra.root)
+		if ra.Cfg.Budget != 0 && expanded > ra.Cfg.Budget {
+			break
+		}
+	}
+}
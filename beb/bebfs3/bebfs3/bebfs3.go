@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"go-glue.googlecode.com/hg/rlglue"
+	"github.com/skelterjohn/rlalg/beb"
+	"github.com/skelterjohn/rlalg/beb/bebfs3"
+	"github.com/skelterjohn/rlalg/telemetry"
+)
+
+type Config struct {
+	BEBFS3		bebfs3.Config
+	Reward		string
+	RewardFiles	string
+	Telemetry	string
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.BEBFS3 = bebfs3.ConfigDefault()
+	argcfg.LoadArgs(&config)
+	if err := config.BEBFS3.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	getRFoo, err := beb.RewardFromArgs(config.RewardFiles, config.Reward)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	agent := bebfs3.New(config.BEBFS3, getRFoo)
+	if agent.Telemetry, err = telemetry.New(config.Telemetry, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := rlglue.LoadAgent(agent); err != nil {
+		fmt.Printf("Error running bebfs3: %v\n", err)
+	}
+}
@@ -0,0 +1,144 @@
+package beb
+
+import (
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/vi"
+)
+
+type BoltConfig struct {
+	Eta	float64
+	Epsilon	float64
+	Alpha	float64
+	RFoo	RewardFunc
+}
+
+func BoltConfigDefault() (cfg BoltConfig) {
+	cfg.Eta = 1
+	cfg.Epsilon = .1
+	cfg.Alpha = 1
+	cfg.RFoo = nil
+	return
+}
+
+//BoltMDP is the optimistic model of Araya-Lopez, Thomas and Buffet: each (s,a)
+//gets Eta extra imagined transitions to Sigma, on top of its Dirichlet posterior.
+//The most optimistic Sigma is the state with the highest value, so the agent
+//alternates between solving for a fixed Sigma and moving Sigma to the new argmax.
+type BoltMDP struct {
+	*BebMDP
+	Eta	float64
+	Sigma	discrete.State
+}
+
+func NewBoltMDP(task *rlglue.TaskSpec, Cfg BoltConfig) (this *BoltMDP) {
+	bcfg := BebConfigDefault()
+	bcfg.Alpha = Cfg.Alpha
+	bcfg.RFoo = Cfg.RFoo
+	this = new(BoltMDP)
+	this.BebMDP = NewBebMDP(task, bcfg)
+	this.Eta = Cfg.Eta
+	return
+}
+func (this *BoltMDP) T(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	alpha0 := this.Alpha0(s, a)
+	alphan := this.Transitions[s][a][n] * alpha0
+	if n == this.Sigma {
+		alphan += this.Eta
+	}
+	if alpha0+this.Eta == 0 {
+		return 0
+	}
+	return alphan / (alpha0 + this.Eta)
+}
+func (this *BoltMDP) R(s discrete.State, a discrete.Action) float64 {
+	return this.BaseR(s, a)
+}
+//value iteration, moving Sigma to the best state until it stops moving. A move
+//has to gain more than epsilon, since approximate values can leave two states
+//trading places, and there are at most as many moves as states.
+func (this *BoltMDP) Solve(qt *discrete.QTable, epsilon float64) {
+	for moves := 0; ; moves++ {
+		vi.ValueIteration(qt, this, epsilon)
+		best := this.Sigma
+		for s := range this.Transitions {
+			if qt.V(discrete.State(s)) > qt.V(best) {
+				best = discrete.State(s)
+			}
+		}
+		if qt.V(best) <= qt.V(this.Sigma)+epsilon || moves == len(this.Transitions) {
+			return
+		}
+		this.Sigma = best
+	}
+	panic("unreachable")
+}
+
+type BoltAgent struct {
+	task		*rlglue.TaskSpec
+	rmdp		*BoltMDP
+	qt		*discrete.QTable
+	lastState	discrete.State
+	lastAction	discrete.Action
+	Cfg		BoltConfig
+	GetRFoo		RewardGetter
+	Telemetry	telemetry.Sink
+}
+
+func NewBoltAgent(Cfg BoltConfig, GetRFoo RewardGetter) (ra *BoltAgent) {
+	ra = new(BoltAgent)
+	ra.Cfg = Cfg
+	ra.Telemetry = telemetry.Nop{}
+	ra.GetRFoo = GetRFoo
+	return
+}
+func (ra *BoltAgent) AgentInit(taskString string) {
+	ra.task, _ = rlglue.ParseTaskSpec(taskString)
+	if ra.task.DiscountFactor == 1 {
+		ra.task.DiscountFactor = 0.99
+	}
+	if ra.GetRFoo != nil {
+		ra.Cfg.RFoo = BuildReward(ra.GetRFoo, ra.task)
+	}
+	ra.rmdp = NewBoltMDP(ra.task, ra.Cfg)
+	ra.qt = discrete.NewQTable(ra.task.Obs.Ints.Count(), ra.task.Act.Ints.Count())
+	ra.rmdp.Solve(ra.qt, ra.Cfg.Epsilon)
+}
+func (ra *BoltAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	ra.lastState = discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *BoltAgent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	nextState := discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	learned := ra.rmdp.Observe(ra.lastState, ra.lastAction, nextState, reward)
+	if learned {
+		ra.rmdp.Solve(ra.qt, ra.Cfg.Epsilon)
+	}
+	ra.lastState = nextState
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *BoltAgent) AgentEnd(reward float64) {
+	learned := ra.rmdp.ObserveTerminal(ra.lastState, ra.lastAction, reward)
+	if learned {
+		ra.rmdp.Solve(ra.qt, ra.Cfg.Epsilon)
+	}
+}
+func (ra *BoltAgent) chooseAction() (action discrete.Action) {
+	action = ra.qt.Pi(ra.lastState)
+	q := make([]float64, ra.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = ra.qt.Q(ra.lastState, discrete.Action(a))
+	}
+	ra.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: action.Hashcode(), Q: q})
+	return
+}
+func (ra *BoltAgent) AgentCleanup() {
+}
+func (ra *BoltAgent) AgentMessage(message string) string {
+	return ""
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"go-glue.googlecode.com/hg/rlglue"
+	"github.com/skelterjohn/rlalg/beb"
+	"github.com/skelterjohn/rlalg/telemetry"
+)
+
+type Config struct {
+	BOLT		beb.BoltConfig
+	Reward		string
+	RewardFiles	string
+	Telemetry	string
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.BOLT = beb.BoltConfigDefault()
+	argcfg.LoadArgs(&config)
+	getRFoo, err := beb.RewardFromArgs(config.RewardFiles, config.Reward)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	agent := beb.NewBoltAgent(config.BOLT, getRFoo)
+	if agent.Telemetry, err = telemetry.New(config.Telemetry, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := rlglue.LoadAgent(agent); err != nil {
+		fmt.Printf("Error running bolt: %v\n", err)
+	}
+}
@@ -0,0 +1,261 @@
+package beb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+)
+
+//a RewardGetter builds a RewardFunc for a particular task, or says why it can't
+type RewardGetter func(task *rlglue.TaskSpec) (foo RewardFunc, err os.Error)
+
+var rewardGetters = make(map[string]RewardGetter)
+var rewardNames []string
+
+//RegisterReward makes getter available under name. Registering the same name
+//twice is a programming error and panics.
+func RegisterReward(name string, getter RewardGetter) {
+	if _, ok := rewardGetters[name]; ok {
+		panic(fmt.Sprintf("beb: reward function %q registered twice", name))
+	}
+	rewardGetters[name] = getter
+	rewardNames = append(rewardNames, name)
+}
+
+func RewardNames() (names []string) {
+	return append(names, rewardNames...)
+}
+
+//LookupReward finds the getter registered under name. The empty name and
+//"learned" give a nil getter, so the agent uses the rewards it observes.
+func LookupReward(name string) (getter RewardGetter, err os.Error) {
+	if name == "" || name == "learned" {
+		return
+	}
+	getter, ok := rewardGetters[name]
+	if !ok {
+		err = fmt.Errorf("beb: unknown reward function %q, have learned, %s", name, strings.Join(rewardNames, ", "))
+	}
+	return
+}
+
+//RewardFromArgs loads the comma-separated reward files, then looks up name
+//among the built-in and loaded reward functions, for the agents' commands.
+func RewardFromArgs(files, name string) (getter RewardGetter, err os.Error) {
+	if files != "" {
+		for _, path := range strings.Split(files, ",", -1) {
+			if err = LoadRewardFile(path); err != nil {
+				return
+			}
+		}
+	}
+	return LookupReward(name)
+}
+
+//BuildReward runs getter on the task the agent was given. A reward function
+//that doesn't fit the task is reported like a bad command line argument, since
+//the agent has no one else to tell.
+func BuildReward(getter RewardGetter, task *rlglue.TaskSpec) (foo RewardFunc) {
+	foo, err := getter(task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	return
+}
+
+func init() {
+	RegisterReward("step", GetRewardStep)
+	RegisterReward("paint", GetRewardPaint)
+}
+
+//-1 for every step
+func GetRewardStep(task *rlglue.TaskSpec) (foo RewardFunc, err os.Error) {
+	foo = func(s discrete.State, a discrete.Action) (r float64) {
+		return -1
+	}
+	return
+}
+func GetRewardPaint(task *rlglue.TaskSpec) (foo RewardFunc, err os.Error) {
+	foo = func(s discrete.State, a discrete.Action) (r float64) {
+		svalues := task.Obs.Ints.Values(s.Hashcode())
+		avalues := task.Act.Ints.Values(a.Hashcode())
+		if avalues[1] == 3 {
+			which := avalues[0]
+			objvals := svalues[which*4 : (which+1)*4]
+			if objvals[0] == 1 && objvals[1] == 1 && objvals[2] == 0 && objvals[3] == 0 {
+				return 10
+			} else {
+				return -1000000
+			}
+		}
+		return -1
+	}
+	return
+}
+
+//A RewardTable gives R(s,a) in terms of the task spec's observation and action
+//values. The first row whose patterns match wins; a nil pattern entry is a
+//wildcard.
+type RewardTable struct {
+	Name	string
+	Default	float64
+	Rows	[]RewardRow
+}
+type RewardRow struct {
+	Obs, Act	[]*int32
+	R		float64
+}
+
+func matchValues(pattern []*int32, values []int32) bool {
+	for i, p := range pattern {
+		if p != nil && *p != values[i] {
+			return false
+		}
+	}
+	return true
+}
+//GetReward gives the table's RewardFunc for task, or an error if the rows
+//don't have as many observation and action values as the task.
+func (this *RewardTable) GetReward(task *rlglue.TaskSpec) (foo RewardFunc, err os.Error) {
+	numObs := len(task.Obs.Ints.Values(0))
+	numAct := len(task.Act.Ints.Values(0))
+	if len(this.Rows) != 0 {
+		row := this.Rows[0]
+		if len(row.Obs) != numObs || len(row.Act) != numAct {
+			err = fmt.Errorf("beb: reward table %q has %d:%d values, task has %d:%d", this.Name, len(row.Obs), len(row.Act), numObs, numAct)
+			return
+		}
+	}
+	foo = func(s discrete.State, a discrete.Action) (r float64) {
+		svalues := task.Obs.Ints.Values(s.Hashcode())
+		avalues := task.Act.Ints.Values(a.Hashcode())
+		for _, row := range this.Rows {
+			if matchValues(row.Obs, svalues) && matchValues(row.Act, avalues) {
+				return row.R
+			}
+		}
+		return this.Default
+	}
+	return
+}
+
+func parsePattern(fields []string) (pattern []*int32, err os.Error) {
+	pattern = make([]*int32, len(fields))
+	for i, field := range fields {
+		if field == "*" {
+			continue
+		}
+		var v int
+		if v, err = strconv.Atoi(field); err != nil {
+			return
+		}
+		v32 := int32(v)
+		pattern[i] = &v32
+	}
+	return
+}
+
+//LoadRewardFile reads reward tables from a file and registers each one under
+//its name. The format is line based, with # starting a comment:
+//
+//	name paint-simple
+//	default -1
+//	* * 1 1 0 0 : 0 3 = 10
+//
+//"name" starts a new table, "default" sets the reward when no row matches, and
+//each row lists the observation values, then the action values, then the reward,
+//with * matching any value.
+func LoadRewardFile(path string) (err os.Error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var tables []*RewardTable
+	var table *RewardTable
+	r := bufio.NewReader(f)
+	for lineno := 1; ; lineno++ {
+		line, rerr := r.ReadString('\n')
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 0 {
+			if err = parseRewardLine(fields, &tables, &table); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, lineno, err)
+			}
+		}
+		if rerr == os.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	for _, table := range tables {
+		RegisterReward(table.Name, table.GetReward)
+	}
+	return
+}
+func parseRewardLine(fields []string, tables *[]*RewardTable, table **RewardTable) (err os.Error) {
+	switch fields[0] {
+	case "name":
+		if len(fields) != 2 {
+			return fmt.Errorf("name takes one argument")
+		}
+		if _, ok := rewardGetters[fields[1]]; ok {
+			return fmt.Errorf("reward function %q already registered", fields[1])
+		}
+		for _, other := range *tables {
+			if other.Name == fields[1] {
+				return fmt.Errorf("reward table %q named twice", fields[1])
+			}
+		}
+		*table = &RewardTable{Name: fields[1]}
+		*tables = append(*tables, *table)
+		return
+	}
+	if *table == nil {
+		return fmt.Errorf("expected name before %q", fields[0])
+	}
+	if fields[0] == "default" {
+		if len(fields) != 2 {
+			return fmt.Errorf("default takes one argument")
+		}
+		(*table).Default, err = strconv.Atof64(fields[1])
+		return
+	}
+	var colon, equals int
+	for i, field := range fields {
+		switch field {
+		case ":":
+			colon = i
+		case "=":
+			equals = i
+		}
+	}
+	if colon == 0 || equals < colon || equals != len(fields)-2 {
+		return fmt.Errorf("expected \"obs... : act... = r\"")
+	}
+	var row RewardRow
+	if row.Obs, err = parsePattern(fields[:colon]); err != nil {
+		return
+	}
+	if row.Act, err = parsePattern(fields[colon+1 : equals]); err != nil {
+		return
+	}
+	if row.R, err = strconv.Atof64(fields[len(fields)-1]); err != nil {
+		return
+	}
+	//the task's width is only known at AgentInit, but the rows must agree now
+	if rows := (*table).Rows; len(rows) != 0 && (len(rows[0].Obs) != len(row.Obs) || len(rows[0].Act) != len(row.Act)) {
+		return fmt.Errorf("row has %d:%d values, earlier rows have %d:%d", len(row.Obs), len(row.Act), len(rows[0].Obs), len(rows[0].Act))
+	}
+	(*table).Rows = append((*table).Rows, row)
+	return
+}
@@ -0,0 +1,135 @@
+package beb
+
+import (
+	"math"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/vi"
+)
+
+type VbrbConfig struct {
+	Beta	float64
+	Epsilon	float64
+	Alpha	float64
+	RFoo	RewardFunc
+}
+
+func VbrbConfigDefault() (cfg VbrbConfig) {
+	cfg.Beta = 1
+	cfg.Epsilon = .1
+	cfg.Alpha = 1
+	cfg.RFoo = nil
+	return
+}
+
+//VbrbMDP is the variance-based reward bonus model of Sorg, Singh and Lewis:
+//the bonus is Beta times the posterior standard deviation of Q(s,a), taken
+//against the current value estimates in QT.
+type VbrbMDP struct {
+	*BebMDP
+	QT	*discrete.QTable
+}
+
+func NewVbrbMDP(task *rlglue.TaskSpec, Cfg VbrbConfig, qt *discrete.QTable) (this *VbrbMDP) {
+	bcfg := BebConfigDefault()
+	bcfg.Beta = Cfg.Beta
+	bcfg.Alpha = Cfg.Alpha
+	bcfg.RFoo = Cfg.RFoo
+	this = new(VbrbMDP)
+	this.BebMDP = NewBebMDP(task, bcfg)
+	this.QT = qt
+	return
+}
+//posterior variance of R(s,a) + gamma*E[V(s')]
+func (this *VbrbMDP) VarQ(s discrete.State, a discrete.Action) (v float64) {
+	n := this.Count(s, a)
+	if this.RFoo == nil {
+		if n < 2 {
+			spread := (this.Task.Reward.Max - this.Task.Reward.Min) / 2
+			v += spread * spread / (n + 1)
+		} else {
+			v += this.VarR(s, a) / n
+		}
+	}
+	var ev, ev2 float64
+	for n := range this.Transitions[s][a] {
+		p := this.Transitions[s][a][n]
+		vn := this.QT.V(discrete.State(n))
+		ev += p * vn
+		ev2 += p * vn * vn
+	}
+	v += this.Gamma * this.Gamma * (ev2 - ev*ev) / (this.Alpha0(s, a) + 1)
+	return
+}
+func (this *VbrbMDP) R(s discrete.State, a discrete.Action) float64 {
+	return this.BaseR(s, a) + this.Beta*math.Sqrt(this.VarQ(s, a))
+}
+
+type VbrbAgent struct {
+	task		*rlglue.TaskSpec
+	rmdp		*VbrbMDP
+	qt		*discrete.QTable
+	lastState	discrete.State
+	lastAction	discrete.Action
+	Cfg		VbrbConfig
+	GetRFoo		RewardGetter
+	Telemetry	telemetry.Sink
+}
+
+func NewVbrbAgent(Cfg VbrbConfig, GetRFoo RewardGetter) (ra *VbrbAgent) {
+	ra = new(VbrbAgent)
+	ra.Cfg = Cfg
+	ra.Telemetry = telemetry.Nop{}
+	ra.GetRFoo = GetRFoo
+	return
+}
+func (ra *VbrbAgent) AgentInit(taskString string) {
+	ra.task, _ = rlglue.ParseTaskSpec(taskString)
+	if ra.task.DiscountFactor == 1 {
+		ra.task.DiscountFactor = 0.99
+	}
+	if ra.GetRFoo != nil {
+		ra.Cfg.RFoo = BuildReward(ra.GetRFoo, ra.task)
+	}
+	ra.qt = discrete.NewQTable(ra.task.Obs.Ints.Count(), ra.task.Act.Ints.Count())
+	ra.rmdp = NewVbrbMDP(ra.task, ra.Cfg, ra.qt)
+	vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
+}
+func (ra *VbrbAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	ra.lastState = discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *VbrbAgent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	nextState := discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
+	learned := ra.rmdp.Observe(ra.lastState, ra.lastAction, nextState, reward)
+	if learned {
+		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
+	}
+	ra.lastState = nextState
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
+	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
+	return
+}
+func (ra *VbrbAgent) AgentEnd(reward float64) {
+	learned := ra.rmdp.ObserveTerminal(ra.lastState, ra.lastAction, reward)
+	if learned {
+		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
+	}
+}
+func (ra *VbrbAgent) chooseAction() (action discrete.Action) {
+	action = ra.qt.Pi(ra.lastState)
+	q := make([]float64, ra.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = ra.qt.Q(ra.lastState, discrete.Action(a))
+	}
+	ra.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: action.Hashcode(), Q: q})
+	return
+}
+func (ra *VbrbAgent) AgentCleanup() {
+}
+func (ra *VbrbAgent) AgentMessage(message string) string {
+	return ""
+}
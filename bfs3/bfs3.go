@@ -1,14 +1,15 @@
 package bfs3
 
 import (
-	"time"
-	"os"
-	"fmt"
-	"gostat.googlecode.com/hg/stat"
+	"strings"
+	"strconv"
+	"rand"
 	"go-glue.googlecode.com/hg/rlglue"
 	"go-glue.googlecode.com/hg/rltools/discrete"
 	"github.com/skelterjohn/rlbayes"
 	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/telemetry"
 )
 
 type Prior func(task *rlglue.TaskSpec) bayes.BeliefState
@@ -16,9 +17,18 @@ type Config struct {
 	MaxTrajectories	uint64
 	Depth		uint64
 	Budget		uint64
+	//nanoseconds per decision. If set, planning runs until it has passed and
+	//MaxTrajectories and Budget are ignored.
+	Deadline	int64
+	//stop planning once the best action is provably best, or once the root's
+	//value bounds are within Epsilon
+	StopSeparated	bool
+	Epsilon		float64
 	ReplanEachStep	bool
 	CustomGammaV	bool
 	Gamma		float64
+	//used with CustomGammaV. Otherwise Vmin and Vmax come from the task's reward
+	//range.
 	Vmin, Vmax	float64
 	FS3		fsss.Config
 }
@@ -27,6 +37,9 @@ func ConfigDefault() (cfg Config) {
 	cfg.MaxTrajectories = 500
 	cfg.Depth = 10
 	cfg.Budget = 1000
+	cfg.Deadline = 0
+	cfg.StopSeparated = false
+	cfg.Epsilon = 0
 	cfg.ReplanEachStep = false
 	cfg.CustomGammaV = false
 	cfg.Gamma = 0.9
@@ -43,14 +56,19 @@ type BFS3Agent struct {
 	stepsWithPlanner	uint64
 	discoveries		uint64
 	fs3			*fsss.Searcher
+	root			*fsss.Node
 	Cfg			Config
 	Counter			uint64
-	Dump			bool
+	LastPlan		plan.Stats
+	Telemetry		telemetry.Sink
+	//seeds each new planner, and picks actions before there is one
+	rng			*rand.Rand
 }
 
 func New(prior Prior) (this *BFS3Agent) {
 	this = new(BFS3Agent)
 	this.prior = prior
+	this.Telemetry = telemetry.Nop{}
 	return
 }
 func (this *BFS3Agent) GetBelief() bayes.BeliefState {
@@ -66,88 +84,110 @@ func (this *BFS3Agent) getStateIndex(state rlglue.Observation) (index uint64) {
 	return this.task.Obs.Ints.Index(state.Ints())
 }
 func (this *BFS3Agent) getAction() (index discrete.Action) {
-	if this.fs3.Dump {
-		println("getAction")
-	}
 	if this.Cfg.ReplanEachStep {
 		this.ResetPlanner()
 	}
 	if this.fs3 == nil {
-		index = discrete.Action(stat.NextRange(int64(this.task.Act.Ints.Count())))
+		index = discrete.Action(this.rng.Int63n(int64(this.task.Act.Ints.Count())))
 		return
 	}
-	node := this.fs3.GetNode(this.stepsWithPlanner, this.belief)
-	var expanded uint64
-	for i := uint64(0); i < this.Cfg.MaxTrajectories; i++ {
-		expandedThisTime := this.fs3.RunTrajectory(node, this.Cfg.Depth)
-		expanded += expandedThisTime
-		if this.Cfg.Budget != 0 && expanded > this.Cfg.Budget {
-			break
-		}
-	}
-	if this.Dump {
-		this.fs3.Dump = true
-		fmt.Printf("root:\n%v\n\n", node)
-		this.fs3.RunTrajectory(node, this.Cfg.Depth)
+	if this.root == nil {
+		this.root = this.fs3.GetNode(this.stepsWithPlanner, this.belief)
+		this.fs3.SetRoot(this.root)
 	}
+	node := this.root
+	this.LastPlan = this.fs3.Plan(node, this.Cfg.Depth, this.limits())
 	index = discrete.Action(this.fs3.GetAction(node))
-	fmt.Fprintf(os.Stderr, "%v\n", this.fs3.GetQs(node))
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: this.stepsWithPlanner, Action: uint64(index), Q: this.fs3.GetQs(node)})
 	if !this.Cfg.FS3.Shallow {
-		this.fs3.ClearLevel(this.stepsWithPlanner)
 		this.stepsWithPlanner++
 	}
-	time.Sleep(1e9)
+	return
+}
+func (this *BFS3Agent) limits() (lim plan.Limits) {
+	lim.Separated = this.Cfg.StopSeparated
+	lim.Epsilon = this.Cfg.Epsilon
+	if this.Cfg.Deadline != 0 {
+		lim.Deadline = this.Cfg.Deadline
+		return
+	}
+	lim.Trajectories = this.Cfg.MaxTrajectories
+	lim.Budget = this.Cfg.Budget
 	return
 }
 func (this *BFS3Agent) ResetPlanner() {
 	this.fs3 = fsss.New()
+	this.root = nil
 	this.fs3.Cfg = this.Cfg.FS3
+	this.fs3.Seed(this.rng.Int63())
+	this.fs3.Telemetry = this.Telemetry
 	this.fs3.NumActions = uint64(this.task.Act.Ints.Count())
 	if this.Cfg.CustomGammaV {
 		this.fs3.Gamma = this.Cfg.Gamma
-		this.fs3.Vmin = this.Cfg.Vmin
-		this.fs3.Vmax = this.Cfg.Vmax
 	} else {
 		this.fs3.Gamma = this.task.DiscountFactor
 		if this.fs3.Gamma == 1 {
 			this.fs3.Gamma = 0.95
 		}
+	}
+	if this.Cfg.CustomGammaV {
+		this.fs3.Vmin = this.Cfg.Vmin
+		this.fs3.Vmax = this.Cfg.Vmax
+	} else {
 		this.fs3.Vmin = this.task.Reward.Min / (1 - this.fs3.Gamma)
 		this.fs3.Vmax = this.task.Reward.Max / (1 - this.fs3.Gamma)
 	}
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlannerReset, Step: this.stepsWithPlanner})
 }
 func (this *BFS3Agent) AgentInit(taskString string) {
+	if err := this.Cfg.FS3.Check(); err != nil {
+		panic(err.String())
+	}
 	this.task, _ = rlglue.ParseTaskSpec(taskString)
+	if this.rng == nil {
+		this.rng = rand.New(rand.NewSource(this.Cfg.FS3.Seed))
+	}
 	this.belief = this.prior(this.task)
 	this.ResetPlanner()
 }
 func (this *BFS3Agent) AgentStart(state rlglue.Observation) (act rlglue.Action) {
-	if this.fs3.Dump {
-		println("AgentStart")
-	}
 	s := discrete.State(this.getStateIndex(state))
 	if s != this.belief.GetState() {
 		this.belief.Teleport(s)
 	}
+	this.root = nil
 	this.lastAction = this.getAction()
 	act = this.getIndexAction(this.lastAction)
 	return
 }
+//Observe updates belief with a real step: taking a led to s, or to the end of
+//the episode if terminal is set, with reward r. It reports whether the belief
+//learned anything. The agents built on bayes.BeliefState all update this way.
+func Observe(belief bayes.BeliefState, a discrete.Action, s discrete.State, terminal bool, r float64) (next bayes.BeliefState, changed bool) {
+	if terminal {
+		next = belief.UpdateTerminal(a, r)
+	} else {
+		next = belief.Update(a, s, r)
+	}
+	changed = next.LessThan(belief) || belief.LessThan(next)
+	return
+}
 func (this *BFS3Agent) AgentStep(reward float64, state rlglue.Observation) (act rlglue.Action) {
 	s := discrete.State(this.getStateIndex(state))
-	old := this.belief
-	this.belief = this.belief.Update(this.lastAction, s, reward)
-	if this.belief.LessThan(old) || old.LessThan(this.belief) {
+	var changed bool
+	if this.belief, changed = Observe(this.belief, this.lastAction, s, false, reward); changed {
 		this.discoveries++
 	}
+	if this.root != nil && !this.Cfg.ReplanEachStep {
+		this.root = this.fs3.Reroot(this.root, this.lastAction, this.belief)
+	}
 	this.lastAction = this.getAction()
 	act = this.getIndexAction(this.lastAction)
 	return
 }
 func (this *BFS3Agent) AgentEnd(reward float64) {
-	old := this.belief
-	this.belief = this.belief.UpdateTerminal(this.lastAction, reward)
-	if this.belief.LessThan(old) || old.LessThan(this.belief) {
+	var changed bool
+	if this.belief, changed = Observe(this.belief, this.lastAction, 0, true, reward); changed {
 		this.discoveries++
 	}
 	return
@@ -156,5 +196,13 @@ func (this *BFS3Agent) AgentCleanup() {
 	return
 }
 func (this *BFS3Agent) AgentMessage(message string) (reply string) {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		this.rng = rand.New(rand.NewSource(seed))
+		if this.fs3 != nil {
+			this.fs3.Seed(this.rng.Int63())
+		}
+	}
 	return
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"go-glue.googlecode.com/hg/rlglue"
+	"github.com/skelterjohn/rlalg/bfs3"
+	"github.com/skelterjohn/rlalg/telemetry"
+)
+
+type Config struct {
+	BFS3		bfs3.Config
+	Prior		bfs3.PriorConfig
+	Telemetry	string
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.BFS3 = bfs3.ConfigDefault()
+	config.Prior = bfs3.PriorConfigDefault()
+	argcfg.LoadArgs(&config)
+	if err := config.BFS3.FS3.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	prior, err := bfs3.GetPrior(config.Prior)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	agent := bfs3.New(prior)
+	if agent.Telemetry, err = telemetry.New(config.Telemetry, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	agent.Cfg = config.BFS3
+	if err := rlglue.LoadAgent(agent); err != nil {
+		fmt.Printf("Error running bfs3: %v\n", err)
+	}
+}
@@ -0,0 +1,56 @@
+package bfs3
+
+import (
+	"testing"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//one state, so every sample of an action leads to the same state
+const oneStateTask = "VERSION RL-Glue-3.0 PROBLEMTYPE episodic DISCOUNTFACTOR 0.9 OBSERVATIONS INTS (0 0) ACTIONS INTS (0 1) REWARDS (0 1.0)"
+
+//plans from the prior, then reroots on the step that really saw reward r
+func plannedReroot(t *testing.T, r float64) (root, next *fsss.Node, a discrete.Action) {
+	task, err := rlglue.ParseTaskSpec(oneStateTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	belief := NewDirichletPrior(1)(task)
+	s := fsss.New()
+	s.Cfg = fsss.ConfigDefault()
+	s.NumActions = 2
+	s.Gamma = task.DiscountFactor
+	s.Vmin, s.Vmax = 0, task.Reward.Max/(1-s.Gamma)
+	root = s.GetNode(0, belief)
+	s.SetRoot(root)
+	s.Plan(root, 3, plan.Limits{Trajectories: 20})
+	a = s.GetAction(root)
+	stepped := belief.Update(a, discrete.State(0), r)
+	next = s.Reroot(root, a, stepped)
+	return
+}
+
+func TestRerootKeepsMatchingChild(t *testing.T) {
+	//the search sampled a with the unknown reward, Reward.Max, and so does the
+	//real step
+	root, next, a := plannedReroot(t, 1)
+	if _, ok := root.GetBranch(int(a))[next]; !ok {
+		t.Errorf("Reroot made a fresh root instead of reusing the sampled child")
+	}
+	if next.GetVisits() == 0 {
+		t.Errorf("the reused root has no planning below it")
+	}
+}
+
+func TestRerootDropsChildWithOtherRewards(t *testing.T) {
+	//the real step saw 0.5, so the sampled child's values don't hold
+	root, next, a := plannedReroot(t, 0.5)
+	if _, ok := root.GetBranch(int(a))[next]; ok {
+		t.Errorf("Reroot reused a child that was backed up with another reward")
+	}
+	if next.GetVisits() != 0 {
+		t.Errorf("the new root already has planning below it")
+	}
+}
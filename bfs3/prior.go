@@ -0,0 +1,476 @@
+package bfs3
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"rand"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlbayes"
+)
+
+type PriorConfig struct {
+	Name	string
+	Alpha	float64
+}
+
+func PriorConfigDefault() (cfg PriorConfig) {
+	cfg.Name = "fdm"
+	cfg.Alpha = 1
+	return
+}
+
+var priorMakers = map[string]func(cfg PriorConfig) Prior{
+	//flat Dirichlet-multinomial: a Dirichlet for each (s,a) with Alpha
+	//pseudo-counts on every next state
+	"fdm": func(cfg PriorConfig) Prior {
+		return NewDirichletPrior(cfg.Alpha)
+	},
+	//sparse Dirichlet: a Dirichlet for each (s,a) over the next states seen so
+	//far, plus Alpha on one not seen yet
+	"sparse": func(cfg PriorConfig) Prior {
+		return NewSparseDirichletPrior(cfg.Alpha)
+	},
+}
+
+func GetPrior(cfg PriorConfig) (prior Prior, err os.Error) {
+	maker, ok := priorMakers[cfg.Name]
+	if !ok {
+		err = fmt.Errorf("bfs3: unknown prior %q", cfg.Name)
+		return
+	}
+	prior = maker(cfg)
+	return
+}
+
+//NewDirichletPrior gives each (s,a) an independent Dirichlet over next states,
+//with alpha pseudo-counts on every one. Rewards are the mean of those observed,
+//and Reward.Max for untried (s,a)s.
+func NewDirichletPrior(alpha float64) Prior {
+	return newDirichletPrior(alpha, false)
+}
+
+//NewSparseDirichletPrior gives each (s,a) a Dirichlet over the next states it
+//has led to, with their counts, and one more outcome with weight alpha for a
+//next state it has not led to yet, which is any of them with equal chance. Few
+//observations make for a confident posterior, which suits tasks where each
+//(s,a) leads to a handful of the states. Rewards are as in NewDirichletPrior.
+func NewSparseDirichletPrior(alpha float64) Prior {
+	return newDirichletPrior(alpha, true)
+}
+
+func newDirichletPrior(alpha float64, sparse bool) Prior {
+	return func(task *rlglue.TaskSpec) bayes.BeliefState {
+		info := new(dirichletInfo)
+		info.task = task
+		info.numStates = task.Obs.Ints.Count()
+		info.numActions = task.Act.Ints.Count()
+		info.alpha = alpha
+		info.sparse = sparse
+		info.rUnknown = task.Reward.Max
+		b := new(DirichletBelief)
+		b.info = info
+		b.sa = make([]*saCounts, info.numStates*info.numActions)
+		return b
+	}
+}
+
+//what every belief descended from one prior shares
+type dirichletInfo struct {
+	task			*rlglue.TaskSpec
+	numStates, numActions	uint64
+	alpha			float64
+	//is alpha on the unseen next states together, or on each next state?
+	sparse			bool
+	rUnknown		float64
+}
+
+//observations for one (s,a). Never modified once a belief refers to it.
+type saCounts struct {
+	nexts		[]discrete.State
+	counts		[]uint64
+	total		uint64
+	terminal	uint64
+	sumR		float64
+}
+
+func (this *saCounts) lessThan(other *saCounts) bool {
+	if this.total != other.total {
+		return this.total < other.total
+	}
+	if this.terminal != other.terminal {
+		return this.terminal < other.terminal
+	}
+	if len(this.nexts) != len(other.nexts) {
+		return len(this.nexts) < len(other.nexts)
+	}
+	for i := range this.nexts {
+		if this.nexts[i] != other.nexts[i] {
+			return this.nexts[i] < other.nexts[i]
+		}
+		if this.counts[i] != other.counts[i] {
+			return this.counts[i] < other.counts[i]
+		}
+	}
+	//beliefs that saw different rewards value their futures differently, so
+	//the search must not merge them
+	return this.sumR < other.sumR
+}
+
+//the ith next state, counting up, that this has not seen. nexts is sorted.
+func (this *saCounts) unseen(i uint64) (n discrete.State) {
+	n = discrete.State(i)
+	if this == nil {
+		return
+	}
+	for _, seen := range this.nexts {
+		if seen > n {
+			break
+		}
+		n++
+	}
+	return
+}
+
+//with n added, or a terminal transition if terminal is set
+func (this *saCounts) with(n discrete.State, terminal bool, r float64) (next *saCounts) {
+	next = new(saCounts)
+	next.total = 1
+	next.sumR = r
+	if terminal {
+		next.terminal = 1
+	}
+	if this != nil {
+		next.total += this.total
+		next.terminal += this.terminal
+		next.sumR += this.sumR
+		next.nexts = append(next.nexts, this.nexts...)
+		next.counts = append(next.counts, this.counts...)
+	}
+	if terminal {
+		return
+	}
+	i := 0
+	for i < len(next.nexts) && next.nexts[i] < n {
+		i++
+	}
+	if i < len(next.nexts) && next.nexts[i] == n {
+		next.counts[i]++
+		return
+	}
+	next.nexts = append(next.nexts, 0)
+	next.counts = append(next.counts, 0)
+	copy(next.nexts[i+1:], next.nexts[i:])
+	copy(next.counts[i+1:], next.counts[i:])
+	next.nexts[i], next.counts[i] = n, 1
+	return
+}
+
+//DirichletBelief is a bayes.BeliefState for the Dirichlet priors. rlbayes only
+//defines the interface; this one is made to be a search state, so updates share
+//the untouched (s,a) counts with the belief they came from, beliefs hash by
+//their counts, and it can sample with a given generator and draw whole MDPs.
+type DirichletBelief struct {
+	info		*dirichletInfo
+	state		discrete.State
+	terminal	bool
+	sa		[]*saCounts
+	hash		uint64
+}
+
+func mixHash(x uint64) uint64 {
+	x *= 0x9e3779b97f4a7c15
+	x ^= x >> 29
+	return x
+}
+func (this *DirichletBelief) Hashcode() uint64 {
+	h := this.hash + mixHash(uint64(this.state)+1)
+	if this.terminal {
+		h = ^h
+	}
+	return h
+}
+func (this *DirichletBelief) LessThan(oi interface{}) bool {
+	other := oi.(*DirichletBelief)
+	if this.state != other.state {
+		return this.state < other.state
+	}
+	if this.terminal != other.terminal {
+		return other.terminal
+	}
+	if this.hash != other.hash {
+		return this.hash < other.hash
+	}
+	for i := range this.sa {
+		x, y := this.sa[i], other.sa[i]
+		if x == y {
+			continue
+		}
+		if x == nil || y == nil {
+			return x == nil
+		}
+		if x.lessThan(y) {
+			return true
+		}
+		if y.lessThan(x) {
+			return false
+		}
+	}
+	return false
+}
+func (this *DirichletBelief) GetState() discrete.State {
+	return this.state
+}
+func (this *DirichletBelief) Teleport(s discrete.State) {
+	this.state = s
+	this.terminal = false
+}
+func (this *DirichletBelief) Terminal() bool {
+	return this.terminal
+}
+func (this *DirichletBelief) counts(s discrete.State, a discrete.Action) *saCounts {
+	return this.sa[s.Hashcode()*this.info.numActions+a.Hashcode()]
+}
+//posterior mean reward for (s,a)
+func (this *DirichletBelief) MeanR(s discrete.State, a discrete.Action) float64 {
+	c := this.counts(s, a)
+	if c == nil {
+		return this.info.rUnknown
+	}
+	return c.sumR / float64(c.total)
+}
+//the prior's weight on unseen next states in total, and how many there are
+func (this *DirichletBelief) unseen(c *saCounts) (weight float64, count uint64) {
+	count = this.info.numStates
+	if c != nil {
+		count -= uint64(len(c.nexts))
+	}
+	if this.info.sparse {
+		if count == 0 {
+			return
+		}
+		return this.info.alpha, count
+	}
+	return this.info.alpha * float64(this.info.numStates), this.info.numStates
+}
+
+//posterior predictive probability of s,a->n
+func (this *DirichletBelief) Mean(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	c := this.counts(s, a)
+	prior, unseen := this.unseen(c)
+	alpha0 := prior
+	var count float64
+	if c != nil {
+		alpha0 += float64(c.total)
+		for i, cn := range c.nexts {
+			if cn == n {
+				count += float64(c.counts[i])
+			}
+		}
+	}
+	if alpha0 == 0 {
+		return 1 / float64(this.info.numStates)
+	}
+	if !this.info.sparse {
+		count += this.info.alpha
+	} else if count == 0 {
+		count = prior / float64(unseen)
+	}
+	return count / alpha0
+}
+func (this *DirichletBelief) update(a discrete.Action, n discrete.State, terminal bool, r float64) (next *DirichletBelief) {
+	index := this.state.Hashcode()*this.info.numActions + a.Hashcode()
+	next = new(DirichletBelief)
+	next.info = this.info
+	next.sa = append([]*saCounts{}, this.sa...)
+	next.sa[index] = this.sa[index].with(n, terminal, r)
+	next.state = this.state
+	next.terminal = terminal
+	if !terminal {
+		next.state = n
+	}
+	key := index*(this.info.numStates+1) + this.info.numStates
+	if !terminal {
+		key = index*(this.info.numStates+1) + n.Hashcode()
+	}
+	next.hash = this.hash + mixHash(key)
+	return
+}
+func (this *DirichletBelief) Update(a discrete.Action, n discrete.State, r float64) bayes.BeliefState {
+	return this.update(a, n, false, r)
+}
+func (this *DirichletBelief) UpdateTerminal(a discrete.Action, r float64) bayes.BeliefState {
+	return this.update(a, 0, true, r)
+}
+//sample a next state (or termination) from the posterior predictive, drawing
+//from rng, or from the shared generator if rng is nil
+func (this *DirichletBelief) sampleNext(a discrete.Action, rng *rand.Rand) (n discrete.State, terminal bool) {
+	c := this.counts(this.state, a)
+	prior, unseen := this.unseen(c)
+	alpha0 := prior
+	if c != nil {
+		alpha0 += float64(c.total)
+	}
+	if alpha0 == 0 {
+		n = discrete.State(uint64(randFloat64(rng) * float64(this.info.numStates)))
+		return
+	}
+	u := randFloat64(rng) * alpha0
+	if u < prior {
+		i := uint64(u / prior * float64(unseen))
+		if i >= unseen {
+			i = unseen - 1
+		}
+		if this.info.sparse {
+			n = c.unseen(i)
+		} else {
+			n = discrete.State(i)
+		}
+		return
+	}
+	u -= prior
+	for i, count := range c.counts {
+		if u < float64(count) {
+			n = c.nexts[i]
+			return
+		}
+		u -= float64(count)
+	}
+	terminal = c.terminal != 0
+	if !terminal {
+		n = c.nexts[len(c.nexts)-1]
+	}
+	return
+}
+func (this *DirichletBelief) Next(a discrete.Action) (o discrete.Oracle, r float64) {
+	return this.NextFrom(a, nil)
+}
+//NextFrom is Next drawing from rng, which makes the belief an fsss.SourcedOracle.
+func (this *DirichletBelief) NextFrom(a discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64) {
+	r = this.MeanR(this.state, a)
+	n, terminal := this.sampleNext(a, rng)
+	o = this.update(a, n, terminal, r)
+	return
+}
+
+func randFloat64(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+//An MDPSampler is a belief state that can draw a complete MDP from its
+//posterior, using rng.
+type MDPSampler interface {
+	SampleMDP(rng *rand.Rand) discrete.MDP
+}
+
+//SampledMDP is a tabular MDP drawn from a belief. Transitions that do not sum
+//to one leave the remainder as the chance of termination.
+type SampledMDP struct {
+	discrete.FlatMDP
+}
+
+func (this *SampledMDP) T(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	return this.Transitions[s][a][n]
+}
+func (this *SampledMDP) R(s discrete.State, a discrete.Action) float64 {
+	return this.Rewards[s][a]
+}
+
+//a draw from Gamma(shape, 1) using rng, by Marsaglia and Tsang
+func sampleGamma(shape float64, rng *rand.Rand) float64 {
+	if shape < 1 {
+		return sampleGamma(shape+1, rng) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+	panic("unreachable")
+}
+
+//A TransitionSampler is a belief state that can draw a single (s,a)'s
+//next-state distribution from its posterior using rng, so MDPs can be sampled
+//lazily.
+//Probabilities that do not sum to one leave the remainder as the chance of
+//termination.
+type TransitionSampler interface {
+	SampleTransition(s discrete.State, a discrete.Action, rng *rand.Rand) (ps []float64)
+	MeanR(s discrete.State, a discrete.Action) float64
+}
+
+//SampleTransition draws (s,a)'s next-state distribution from its Dirichlet
+//posterior, with termination as one more outcome once it has been seen. Under
+//the sparse prior, the unseen next states share one draw evenly.
+func (this *DirichletBelief) SampleTransition(s discrete.State, a discrete.Action, rng *rand.Rand) (ps []float64) {
+	ps = make([]float64, this.info.numStates)
+	params := make([]float64, this.info.numStates)
+	if !this.info.sparse {
+		for n := range params {
+			params[n] = this.info.alpha
+		}
+	}
+	var terminal float64
+	c := this.counts(s, a)
+	if c != nil {
+		for i, n := range c.nexts {
+			params[n] += float64(c.counts[i])
+		}
+		terminal = float64(c.terminal)
+	}
+	var total float64
+	for n, param := range params {
+		if param > 0 {
+			ps[n] = sampleGamma(param, rng)
+			total += ps[n]
+		}
+	}
+	if prior, unseen := this.unseen(c); this.info.sparse && prior > 0 {
+		share := sampleGamma(prior, rng)
+		total += share
+		for i := uint64(0); i < unseen; i++ {
+			ps[c.unseen(i)] = share / float64(unseen)
+		}
+	}
+	if terminal > 0 {
+		total += sampleGamma(terminal, rng)
+	}
+	if total > 0 {
+		for n := range ps {
+			ps[n] /= total
+		}
+	}
+	return
+}
+
+//SampleMDP draws every (s,a)'s next-state distribution with SampleTransition.
+//The rewards are the posterior means.
+func (this *DirichletBelief) SampleMDP(rng *rand.Rand) discrete.MDP {
+	mdp := new(SampledMDP)
+	mdp.Task = this.info.task
+	mdp.Gamma = this.info.task.DiscountFactor
+	mdp.Transitions = make([][][]float64, this.info.numStates)
+	mdp.Rewards = make([][]float64, this.info.numStates)
+	for s := range mdp.Transitions {
+		mdp.Transitions[s] = make([][]float64, this.info.numActions)
+		mdp.Rewards[s] = make([]float64, this.info.numActions)
+		for a := range mdp.Transitions[s] {
+			mdp.Transitions[s][a] = this.SampleTransition(discrete.State(s), discrete.Action(a), rng)
+			mdp.Rewards[s][a] = this.MeanR(discrete.State(s), discrete.Action(a))
+		}
+	}
+	return mdp
+}
@@ -0,0 +1,202 @@
+package boss
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"strconv"
+	"rand"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlbayes"
+	"github.com/skelterjohn/rlalg/bfs3"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/vi"
+)
+
+type Config struct {
+	//how many MDPs to sample, at least 1
+	K	uint64
+	//how many tries make an (s,a) known
+	B	uint64
+	Epsilon	float64
+	//seeds the MDP draws, unless the agent gets a seed message
+	Seed	int64
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.K = 5
+	cfg.B = 10
+	cfg.Epsilon = 0.1
+	cfg.Seed = 1
+	return
+}
+
+//Check reports settings the agent cannot run with.
+func (cfg Config) Check() (err os.Error) {
+	if cfg.K == 0 {
+		err = fmt.Errorf("boss: K must be at least 1")
+	}
+	return
+}
+
+//MergedMDP puts several MDPs over the same states side by side: action a of
+//sample k is action k*n+a of the merged MDP, where n is the sample's action count.
+type MergedMDP struct {
+	discrete.MDP
+	Samples		[]discrete.MDP
+	sampleActions	uint64
+}
+
+func NewMergedMDP(samples []discrete.MDP) (this *MergedMDP) {
+	this = new(MergedMDP)
+	this.MDP = samples[0]
+	this.Samples = samples
+	this.sampleActions = samples[0].NumActions()
+	return
+}
+func (this *MergedMDP) NumActions() uint64 {
+	return uint64(len(this.Samples)) * this.sampleActions
+}
+func (this *MergedMDP) A64() <-chan discrete.Action {
+	c := make(chan discrete.Action)
+	go func() {
+		for a := uint64(0); a < this.NumActions(); a++ {
+			c <- discrete.Action(a)
+		}
+		close(c)
+	}()
+	return c
+}
+//Split gives the sample a merged action comes from, and that sample's action.
+func (this *MergedMDP) Split(a discrete.Action) (k uint64, sa discrete.Action) {
+	k = a.Hashcode() / this.sampleActions
+	sa = discrete.Action(a.Hashcode() % this.sampleActions)
+	return
+}
+func (this *MergedMDP) T(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	k, sa := this.Split(a)
+	return this.Samples[k].T(s, sa, n)
+}
+func (this *MergedMDP) R(s discrete.State, a discrete.Action) float64 {
+	k, sa := this.Split(a)
+	return this.Samples[k].R(s, sa)
+}
+
+//Agent is Best Of Sampled Set (Asmuth, Li, Littman, Nouri and Wingate): each
+//time an (s,a) becomes known it samples K MDPs from its belief, merges them, and
+//acts greedily in the merged MDP. The belief from the prior must implement
+//bfs3.MDPSampler.
+type Agent struct {
+	task		*rlglue.TaskSpec
+	prior		bfs3.Prior
+	belief		bayes.BeliefState
+	counts		[][]uint64
+	merged		*MergedMDP
+	qt		*discrete.QTable
+	lastAction	discrete.Action
+	Cfg		Config
+	Telemetry	telemetry.Sink
+	rng		*rand.Rand
+}
+
+func New(cfg Config, prior bfs3.Prior) (this *Agent) {
+	this = new(Agent)
+	this.Cfg = cfg
+	this.prior = prior
+	this.Telemetry = telemetry.Nop{}
+	this.rng = rand.New(rand.NewSource(cfg.Seed))
+	return
+}
+func (this *Agent) GetBelief() bayes.BeliefState {
+	return this.belief
+}
+func (this *Agent) AgentInit(taskString string) {
+	if err := this.Cfg.Check(); err != nil {
+		panic(err.String())
+	}
+	this.task, _ = rlglue.ParseTaskSpec(taskString)
+	if this.task.DiscountFactor == 1 {
+		this.task.DiscountFactor = 0.95
+	}
+	this.belief = this.prior(this.task)
+	if _, ok := this.belief.(bfs3.MDPSampler); !ok {
+		panic("boss: the prior's belief cannot sample MDPs")
+	}
+	this.counts = make([][]uint64, this.task.Obs.Ints.Count())
+	for s := range this.counts {
+		this.counts[s] = make([]uint64, this.task.Act.Ints.Count())
+	}
+	this.merged = nil
+}
+//Resample draws K MDPs from the belief, merges them and solves the result.
+func (this *Agent) Resample() {
+	sampler := this.belief.(bfs3.MDPSampler)
+	samples := make([]discrete.MDP, this.Cfg.K)
+	for k := range samples {
+		samples[k] = sampler.SampleMDP(this.rng)
+	}
+	this.merged = NewMergedMDP(samples)
+	this.qt = discrete.NewQTable(this.task.Obs.Ints.Count(), this.merged.NumActions())
+	vi.ValueIteration(this.qt, this.merged, this.Cfg.Epsilon)
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlannerReset})
+}
+func (this *Agent) chooseAction() (act rlglue.Action) {
+	s := this.belief.GetState()
+	_, this.lastAction = this.merged.Split(this.qt.Pi(s))
+	q := make([]float64, this.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = this.qt.Q(s, discrete.Action(a))
+		for k := uint64(1); k < this.Cfg.K; k++ {
+			if qk := this.qt.Q(s, discrete.Action(k*uint64(len(q))+uint64(a))); qk > q[a] {
+				q[a] = qk
+			}
+		}
+	}
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: this.lastAction.Hashcode(), Q: q})
+	act = rlglue.NewAction(this.task.Act.Ints.Values(this.lastAction.Hashcode()), []float64{}, []byte{})
+	return
+}
+//count the try of lastAction from s, and report if it just became known
+func (this *Agent) observe(s discrete.State) (known bool) {
+	this.counts[s][this.lastAction]++
+	return this.counts[s][this.lastAction] == this.Cfg.B
+}
+func (this *Agent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	if s != this.belief.GetState() {
+		this.belief.Teleport(s)
+	}
+	if this.merged == nil {
+		this.Resample()
+	}
+	act = this.chooseAction()
+	return
+}
+func (this *Agent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	known := this.observe(this.belief.GetState())
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, s, false, reward)
+	if known {
+		this.Resample()
+	}
+	act = this.chooseAction()
+	return
+}
+func (this *Agent) AgentEnd(reward float64) {
+	known := this.observe(this.belief.GetState())
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, 0, true, reward)
+	if known {
+		this.merged = nil
+	}
+}
+func (this *Agent) AgentCleanup() {
+}
+func (this *Agent) AgentMessage(message string) string {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		this.rng = rand.New(rand.NewSource(seed))
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"go-glue.googlecode.com/hg/rlglue"
+	"github.com/skelterjohn/rlalg/bfs3"
+	"github.com/skelterjohn/rlalg/boss"
+	"github.com/skelterjohn/rlalg/telemetry"
+)
+
+type Config struct {
+	BOSS		boss.Config
+	Prior		bfs3.PriorConfig
+	Telemetry	string
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.BOSS = boss.ConfigDefault()
+	config.Prior = bfs3.PriorConfigDefault()
+	argcfg.LoadArgs(&config)
+	if err := config.BOSS.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	prior, err := bfs3.GetPrior(config.Prior)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	agent := boss.New(config.BOSS, prior)
+	if agent.Telemetry, err = telemetry.New(config.Telemetry, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := rlglue.LoadAgent(agent); err != nil {
+		fmt.Printf("Error running boss: %v\n", err)
+	}
+}
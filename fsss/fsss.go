@@ -3,8 +3,15 @@ package fsss
 import (
 	"fmt"
 	"math"
+	"os"
+	"rand"
+	"sort"
+	"sync"
 	"gohash.googlecode.com/hg/hashlessmap"
 	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/leaf"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/telemetry"
 )
 
 type Config struct {
@@ -15,6 +22,54 @@ type Config struct {
 	EarlyTermination     bool
 	UseUncertaintyRate   bool
 	ZeroAtDepthThreshold bool
+	//if set, values unexpanded nodes where trajectories stop for depth
+	Leaf leaf.Evaluator
+	//if set, gives each new node its starting value bounds, which should be
+	//sound. Otherwise new nodes start at Vmin and Vmax.
+	Bounds leaf.Evaluator
+	//progressive widening: if WideningK is not 0, an action tried n times gets
+	//WideningK*n^WideningAlpha samples, up to C
+	WideningK     float64
+	WideningAlpha float64
+	//adaptive width: if set, and not widening, an action gets samples until
+	//AdaptiveRepeats in a row land on next states it already had and the
+	//standard error of its reward is within AdaptiveTolerance, up to C. One
+	//that hasn't settled gets another sample each time a trajectory takes it.
+	Adaptive          bool
+	AdaptiveRepeats   uint64
+	AdaptiveTolerance float64
+	//if not 0, nodes are evicted between trajectories to keep at most this many
+	MaxNodes uint64
+	//which nodes go first, "lru" for the least recently visited or "deepest"
+	Eviction string
+	//seeds the Searcher's random source, unless Seed or SetSource is called
+	Seed int64
+	//how many trajectories may run at once, whether Plan runs them or the caller
+	//does from its own goroutines. 0 and 1 both mean one at a time.
+	Workers int
+	//if set, trajectories run one at a time and only each expansion's samples
+	//are drawn by Workers goroutines, so that a seeded search always makes the
+	//same tree. Samples are drawn in turn unless the oracle is a SourcedOracle.
+	//Without it, parallel trajectories race each other, and a seeded search is
+	//not repeatable.
+	Reproducible bool
+}
+
+//Check reports a config the Searcher can't run with.
+func (cfg Config) Check() (err os.Error) {
+	_, err = cfg.evictDeepest()
+	return
+}
+
+func (cfg Config) evictDeepest() (deepest bool, err os.Error) {
+	switch cfg.Eviction {
+	case "", "lru":
+	case "deepest":
+		deepest = true
+	default:
+		err = fmt.Errorf("fsss: unknown eviction order %q", cfg.Eviction)
+	}
+	return
 }
 
 func ConfigDefault() (cfg Config) {
@@ -25,6 +80,18 @@ func ConfigDefault() (cfg Config) {
 	cfg.EarlyTermination = false
 	cfg.UseUncertaintyRate = false
 	cfg.ZeroAtDepthThreshold = false
+	cfg.Leaf = nil
+	cfg.Bounds = nil
+	cfg.WideningK = 0
+	cfg.WideningAlpha = 0.5
+	cfg.Adaptive = false
+	cfg.AdaptiveRepeats = 1
+	cfg.AdaptiveTolerance = 0.01
+	cfg.MaxNodes = 0
+	cfg.Eviction = "lru"
+	cfg.Seed = 1
+	cfg.Workers = 1
+	cfg.Reproducible = false
 	return
 }
 
@@ -34,6 +101,14 @@ type ActionFilter interface {
 	ActionAvailable(action discrete.Action) bool
 }
 
+//A TurnOracle is a state of a two-player zero-sum game where the players take
+//turns. Rewards and values are player 0's: nodes where Turn is 0 back up the max
+//of their actions, and nodes where it is 1 the min. Oracles that are not
+//TurnOracles are all player 0's.
+type TurnOracle interface {
+	Turn() int
+}
+
 type Searcher struct {
 	NodeDepthMaps map[uint64]*hashlessmap.Map
 	Cfg           Config
@@ -41,13 +116,25 @@ type Searcher struct {
 	Vmin, Vmax    float64
 	Gamma         float64
 	lastPathScore float64
+	//guards NodeDepthMaps, lastPathScore and the counts below while
+	//trajectories run in parallel
+	lock sync.Mutex
+	//new nodes take their seeds from this
+	src rand.Source
+	//held for reading by every trajectory, and for writing by eviction
+	treeLock sync.RWMutex
+	//ticks once per node visit, to date them for eviction
+	clock uint64
+	//nodes and sampled branches made since the last count
+	numNodes, numEdges uint64
 
-	Dump bool
+	Telemetry telemetry.Sink
 }
 
 func New() (s *Searcher) {
 	s = &Searcher{}
 	s.NodeDepthMaps = make(map[uint64]*hashlessmap.Map)
+	s.Telemetry = telemetry.Nop{}
 	return
 }
 
@@ -55,44 +142,254 @@ func (s *Searcher) GetNode(depth uint64, o discrete.Oracle) (res *Node) {
 	if o == nil {
 		panic("nil oracle")
 	}
-	if !s.Cfg.Memoize {
-		return newNode(s, o)
-	}
-	if s.Cfg.Shallow {
+	if s.Cfg.Memoize && s.Cfg.Shallow {
 		depth = 0
 	}
 	//fmt.Printf("+*Searcher.GetNode(%v,%v)\n", depth, o)
 	//defer println("-*Searcher.GetNode")
+	s.lock.Lock()
+	if res = s.lookup(depth, o); res != nil {
+		s.lock.Unlock()
+		return
+	}
+	var seed uint64
+	if !o.Terminal() {
+		seed = s.nextSeed()
+	}
+	s.lock.Unlock()
+	//made without the lock, so that Cfg.Bounds doesn't hold up other trajectories
+	n := newNode(s, o, seed)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if res = s.lookup(depth, o); res != nil {
+		//another trajectory made it first
+		return
+	}
+	res = n
+	s.numNodes++
+	if !s.Cfg.Memoize {
+		return
+	}
+	res.depth = depth
+	//fmt.Fprintf(os.Stderr, "%v %v\n", o, res)
 	hmap, ok := s.NodeDepthMaps[depth]
-	if ok {
-		ri, ok := hmap.Get(o)
-		if ok {
-			res = ri.(*Node)
-			return
-		}
-	} else {
+	if !ok {
 		hmap = hashlessmap.New()
 		s.NodeDepthMaps[depth] = hmap
 	}
-	res = newNode(s, o)
-	res.depth = depth
-	//fmt.Fprintf(os.Stderr, "%v %v\n", o, res)
 	hmap.Put(o, res)
+	return
+}
+
+//the memoized node for o at depth, if there is one. The caller holds s.lock.
+func (s *Searcher) lookup(depth uint64, o discrete.Oracle) (res *Node) {
+	if !s.Cfg.Memoize {
+		return
+	}
+	if hmap, ok := s.NodeDepthMaps[depth]; ok {
+		if ri, ok := hmap.Get(o); ok {
+			res = ri.(*Node)
+		}
+	}
+	return
+}
+
+func sameOracle(x, y discrete.Oracle) bool {
+	return x.Hashcode() == y.Hashcode() && !x.LessThan(y) && !y.LessThan(x)
+}
+
+//Reroot finds the node for o among the outcomes of taking a at root, and makes
+//it the new root so that the planning already done below it carries over. The
+//node takes o in place of the equal oracle it was sampled with. An outcome that
+//differs from o in any way, such as a belief that sampled a reward other than
+//the one really seen, was backed up with values that don't hold for o, so it
+//doesn't count. If o was never sampled, the new root is a fresh node one level
+//below root.
+func (s *Searcher) Reroot(root *Node, a discrete.Action, o discrete.Oracle) (next *Node) {
+	if !root.terminal {
+		for nn := range root.branches[a] {
+			if sameOracle(nn.key, o) {
+				next = nn
+				break
+			}
+		}
+	}
+	if next != nil {
+		next.key = o
+		if next.o != nil {
+			next.o = o
+		}
+	}
+	if next == nil {
+		next = s.GetNode(root.depth+1, o)
+	}
+	s.SetRoot(next)
+	return
+}
+
+//reachable lists the nodes that can be reached from root, breadth first, along
+//with how many steps each is from root.
+func reachable(root *Node) (nodes []*Node, distance map[*Node]uint64) {
+	distance = map[*Node]uint64{root: 0}
+	nodes = []*Node{root}
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		for _, nexts := range n.order {
+			for _, nn := range nexts {
+				if _, ok := distance[nn]; !ok {
+					distance[nn] = distance[n] + 1
+					nodes = append(nodes, nn)
+				}
+			}
+		}
+	}
+	return
+}
+
+//SetRoot forgets every memoized node that cannot be reached from root.
+func (s *Searcher) SetRoot(root *Node) {
+	nodes, _ := reachable(root)
+	var edges uint64
+	for _, n := range nodes {
+		for _, branch := range n.branches {
+			edges += uint64(len(branch))
+		}
+	}
+	s.lock.Lock()
+	s.numNodes, s.numEdges = uint64(len(nodes)), edges
+	s.lock.Unlock()
+	if !s.Cfg.Memoize {
+		return
+	}
+	maps := make(map[uint64]*hashlessmap.Map)
+	for _, n := range nodes {
+		hmap, ok := maps[n.depth]
+		if !ok {
+			hmap = hashlessmap.New()
+			maps[n.depth] = hmap
+		}
+		hmap.Put(n.key, n)
+	}
+	s.NodeDepthMaps = maps
+}
+
+//rough sizes for MemStats: a Node, the per-action slices and maps in an expanded
+//one, and one sampled branch
+const (
+	nodeBytes   = 256
+	actionBytes = 5*8 + 8 + 48
+	edgeBytes   = 40
+)
+
+type MemStats struct {
+	Nodes uint64
+	Edges uint64
+	//an estimate of the memory they take up
+	Bytes uint64
+}
 
+//MemStats counts the nodes made since the last SetRoot or eviction. Nodes that
+//have become unreachable since then are still counted.
+func (s *Searcher) MemStats() (stats MemStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats.Nodes, stats.Edges = s.numNodes, s.numEdges
+	stats.Bytes = stats.Nodes*(nodeBytes+s.NumActions*actionBytes) + stats.Edges*edgeBytes
 	return
 }
 
+//Evict collapses expanded nodes below root back into leaves once more than
+//Cfg.MaxNodes remain, until no more than 90% of them do, and forgets the nodes
+//that no longer can be reached. Evicting past the limit leaves the tree room to
+//grow for a while before the next eviction. Only nodes whose children are all
+//leaves are collapsed, so the bounds a collapsed node had stay as they were, and
+//remain sound; its children are gone. Plan calls Evict between trajectories,
+//and callers that run trajectories themselves should too.
+func (s *Searcher) Evict(root *Node) {
+	if s.Cfg.MaxNodes == 0 {
+		return
+	}
+	s.lock.Lock()
+	full := s.numNodes > s.Cfg.MaxNodes
+	s.lock.Unlock()
+	if !full {
+		return
+	}
+	deepest, err := s.Cfg.evictDeepest()
+	if err != nil {
+		panic(err.String())
+	}
+	s.treeLock.Lock()
+	defer s.treeLock.Unlock()
+	lowWater := s.Cfg.MaxNodes - s.Cfg.MaxNodes/10
+	for s.numNodes > lowWater {
+		nodes, distance := reachable(root)
+		var candidates []*Node
+		for _, n := range nodes {
+			if n != root && n.collapsible() {
+				candidates = append(candidates, n)
+			}
+		}
+		if len(candidates) == 0 {
+			s.SetRoot(root)
+			return
+		}
+		sort.Sort(byEviction{candidates, distance, deepest})
+		excess := len(nodes) - int(lowWater)
+		for _, n := range candidates {
+			if excess <= 0 {
+				break
+			}
+			excess -= n.collapse()
+		}
+		s.SetRoot(root)
+	}
+}
+
+//orders eviction candidates, least recently visited first, or farthest from the
+//root first with ties broken by least recently visited
+type byEviction struct {
+	nodes    []*Node
+	distance map[*Node]uint64
+	deepest  bool
+}
+
+func (this byEviction) Len() int {
+	return len(this.nodes)
+}
+func (this byEviction) Less(i, j int) bool {
+	ni, nj := this.nodes[i], this.nodes[j]
+	if this.deepest && this.distance[ni] != this.distance[nj] {
+		return this.distance[ni] > this.distance[nj]
+	}
+	return ni.lastVisit < nj.lastVisit
+}
+func (this byEviction) Swap(i, j int) {
+	this.nodes[i], this.nodes[j] = this.nodes[j], this.nodes[i]
+}
+
 func (s *Searcher) ClearLevel(depth uint64) {
 	//println("+*Searcher.ClearLevel")
 	//defer println("-*Searcher.ClearLevel")
 	s.NodeDepthMaps[depth] = nil, false
 }
+
+//RunTrajectory runs one trajectory from n. Callers may run up to Cfg.Workers of
+//them at once from their own goroutines.
 func (s *Searcher) RunTrajectory(n *Node, length uint64) (expanded uint64) {
-	expanded, s.lastPathScore = s.RunTrajectoryAux(n, length, 0, 0)
+	s.treeLock.RLock()
+	defer s.treeLock.RUnlock()
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.TrajectoryStarted, Depth: n.depth})
+	var pathScore float64
+	expanded, pathScore = s.RunTrajectoryAux(n, length, 0, 0)
+	s.lock.Lock()
+	s.lastPathScore = pathScore
+	s.lock.Unlock()
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.TrajectoryFinished, Depth: n.depth, Expanded: expanded})
 	return
 }
 func (s *Searcher) RunTrajectoryNotify(n *Node, length uint64, notify chan bool) {
-	s.RunTrajectoryAux(n, length, 0, 0)
+	s.RunTrajectory(n, length)
 	notify <- true
 }
 
@@ -108,72 +405,122 @@ func (s *Searcher) RunTrajectoryAux(n *Node, length, depth uint64, pathScore flo
 		panic("RunTrajectory(nil)")
 	}
 
-	if s.Dump {
-		fmt.Printf("%v\n", n)
-	}
-
 	if n.terminal {
 		//print("T")
 		return
 	}
 	if length == 0 {
-		if s.Cfg.ZeroAtDepthThreshold {
-			n.vupper = 0
-			n.vlower = 0
-		}
+		n.evaluate(s.Cfg.Leaf, s.Cfg.ZeroAtDepthThreshold)
 		//print("D")
 		return
 	}
-	if s.Cfg.MaxVisits != 0 && s.Cfg.MaxVisits <= n.visits {
-		//print("M")
-		return
+
+	if s.Cfg.EarlyTermination {
+		s.lock.Lock()
+		behind := pathScore < s.lastPathScore
+		s.lock.Unlock()
+		if behind {
+			//print("E")
+			return
+		}
 	}
 
-	if s.Cfg.EarlyTermination && pathScore < s.lastPathScore {
-		//print("E")
+	if !n.enter() {
+		//print("M")
 		return
 	}
+	defer n.leave()
 
-	n.visits++
-
-	if n.leaf {
-		if !n.expand() {
-			return
-		} else {
-			if s.Dump {
-				fmt.Printf("expanded\n")
-			}
-			expanded += s.Cfg.C * s.NumActions
-		}
+	if drawn := n.expand(); drawn != 0 {
+		s.Telemetry.Emit(telemetry.Event{Kind: telemetry.NodeExpanded, Depth: n.depth})
+		expanded += drawn
+		n.backup()
 	}
 
 	a := n.getBestAction()
 
-	if s.Dump {
-		fmt.Printf("%d %f\n\n", a, n.r[a])
+	if drawn := n.widen(a); drawn != 0 {
+		expanded += drawn
+		n.backup()
 	}
 
-	nn := n.getMostUncertain(a)
+	nn, weight := n.getMostUncertain(a)
 
-	stepScore := math.Log(s.Gamma) + math.Log(n.branches[a][nn]) - math.Log(float64(s.Cfg.C))
+	stepScore := math.Log(s.Gamma) + math.Log(weight)
 
 	var tailExpanded uint64
 	tailExpanded, endPathScore = s.RunTrajectoryAux(nn, length-1, depth+1, pathScore+stepScore)
 	expanded += tailExpanded
 	n.backup()
 
-	if s.Dump {
-		fmt.Printf("backup\n%d %f\n%v\n\n", a, n.r[a], n)
+	vupper, vlower := n.GetValue()
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.Backup, Depth: n.depth, Action: uint64(n.getBestAction()), Vlower: vlower, Vupper: vupper})
+
+	return
+}
+
+
+//converged checks lim's early stopping conditions against root's bounds.
+func (s *Searcher) converged(root *Node, lim plan.Limits) plan.Stop {
+	if lim.Separated && root.separated() {
+		return plan.StopSeparated
+	}
+	if vupper, vlower := root.GetValue(); vupper-vlower < lim.Epsilon {
+		return plan.StopGap
 	}
+	return plan.StopNone
+}
 
+//Plan runs trajectories of the given length from root until one of lim's limits
+//is reached, or root has converged as far as lim asks. With no limits at all it
+//runs nothing. It ignores lim.Workers: with Cfg.Workers above 1 it runs that
+//many trajectories at once, which makes a different tree from run to run even
+//with the same seed, unless Cfg.Reproducible is set.
+func (s *Searcher) Plan(root *Node, length uint64, lim plan.Limits) (stats plan.Stats) {
+	trajectory := func() (expanded uint64) {
+		expanded = s.RunTrajectory(root, length)
+		s.Evict(root)
+		return
+	}
+	converged := func() plan.Stop {
+		return s.converged(root, lim)
+	}
+	if s.parallel() {
+		lim.Workers = s.Cfg.Workers
+		stats = plan.RunParallel(lim, trajectory, converged)
+	} else {
+		stats = plan.Run(lim, trajectory, converged)
+	}
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlanningFinished, Depth: root.depth, Trajectories: stats.Trajectories, Expanded: stats.Expanded, Elapsed: stats.Elapsed, Reason: stats.Stop.String()})
 	return
 }
 
+//parallel reports whether trajectories may run at once, so that backups have
+//to take the locking path.
+func (s *Searcher) parallel() bool {
+	return s.Cfg.Workers > 1 && !s.Cfg.Reproducible
+}
 
 func (s *Searcher) GetAction(n *Node) discrete.Action {
 	return n.getBestAction()
 }
 
+//GetQs returns the bounds n's player chooses by: the upper for the maximizer,
+//the lower for the minimizer.
 func (s *Searcher) GetQs(n *Node) []float64 {
+	if n.minimizing {
+		return n.qlower
+	}
 	return n.qupper
 }
+
+//GetQBounds returns copies of n's lower and upper bounds on each action's value.
+func (s *Searcher) GetQBounds(n *Node) (lower, upper []float64) {
+	n.block.Lock()
+	defer n.block.Unlock()
+	lower = make([]float64, len(n.qlower))
+	upper = make([]float64, len(n.qupper))
+	copy(lower, n.qlower)
+	copy(upper, n.qupper)
+	return
+}
@@ -1,19 +1,34 @@
 package fsssmdp
 
 import (
+	"fmt"
+	"os"
 	"strings"
 	"strconv"
 	"rand"
-	"gostat.googlecode.com/hg/stat"
+	"time"
 	"go-glue.googlecode.com/hg/rlglue"
 	"go-glue.googlecode.com/hg/rltools/discrete"
 	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/sparse"
+	"github.com/skelterjohn/rlalg/telemetry"
 )
 
 type Config struct {
 	Depth		uint64
 	NumTrajectories	uint64
 	Budget		uint64
+	//nanoseconds per decision. If set, planning runs until it has passed and
+	//NumTrajectories and Budget are ignored.
+	Deadline	int64
+	//stop planning once the best action is provably best, or once the root's
+	//value bounds are within Epsilon
+	StopSeparated	bool
+	Epsilon		float64
+	//"fsss", or "sparse" for exhaustive sparse sampling with width FS3.C and
+	//depth Depth, which ignores the other limits
+	Planner		string
 	FS3		fsss.Config
 }
 
@@ -21,39 +36,84 @@ func ConfigDefault() (cfg Config) {
 	cfg.Depth = 10
 	cfg.NumTrajectories = 100
 	cfg.Budget = 1000
+	cfg.Deadline = 0
+	cfg.StopSeparated = false
+	cfg.Epsilon = 0
+	cfg.Planner = "fsss"
 	cfg.FS3 = fsss.ConfigDefault()
 	return
 }
 
+//Check reports settings the agent cannot run with.
+func (cfg Config) Check() (err os.Error) {
+	switch cfg.Planner {
+	case "", "fsss":
+	case "sparse":
+		if cfg.FS3.C == 0 {
+			return fmt.Errorf("fsssmdp: sparse sampling needs FS3.C at least 1")
+		}
+		if cfg.Depth == 0 {
+			return fmt.Errorf("fsssmdp: sparse sampling needs Depth at least 1")
+		}
+	default:
+		return fmt.Errorf("fsssmdp: unknown planner %q", cfg.Planner)
+	}
+	return cfg.FS3.Check()
+}
+
 type Agent struct {
 	cfg			Config
 	mdp			discrete.MDP
 	lastState		discrete.State
 	lastAction		discrete.Action
 	s			*fsss.Searcher
-	mdpo			*discrete.MDPOracle
+	mdpo			*Oracle
+	rng			*rand.Rand
+	root			*fsss.Node
+	sparse			*sparse.Planner
+	qs			[]float64
 	stepsWithPlanner	uint64
+	Telemetry		telemetry.Sink
+	LastPlan		plan.Stats
 }
 
 func New(cfg Config, mdp discrete.MDP) (this *Agent) {
+	if err := cfg.Check(); err != nil {
+		panic(err.String())
+	}
 	this = new(Agent)
 	this.cfg = cfg
 	this.mdp = mdp
-	this.mdpo = discrete.NewMDPOracle(this.mdp, 0)
+	this.mdpo = NewOracle(this.mdp, 0)
 	this.s = fsss.New()
 	this.s.Cfg = this.cfg.FS3
 	this.s.NumActions = this.mdp.NumActions()
 	this.s.Gamma = mdp.GetGamma()
 	this.s.Vmin = this.mdp.GetTask().Reward.Min / (1 - this.s.Gamma)
 	this.s.Vmax = this.mdp.GetTask().Reward.Max / (1 - this.s.Gamma)
+	if this.cfg.Planner == "sparse" {
+		this.sparse = sparse.New(this.cfg.FS3.C, this.s.NumActions, this.s.Gamma)
+		this.sparse.Leaf = this.cfg.FS3.Leaf
+	}
 	this.stepsWithPlanner = 0
+	this.Telemetry = telemetry.Nop{}
+	this.Seed(cfg.FS3.Seed)
 	return
 }
+//Seed reseeds this agent's and its searcher's random sources.
+func (this *Agent) Seed(seed int64) {
+	this.rng = rand.New(rand.NewSource(seed))
+	this.s.Seed(seed)
+	if this.sparse != nil {
+		this.sparse.Seed(seed)
+	}
+}
 func (*Agent) AgentInit(taskString string) {
 }
 func (this *Agent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
 	this.stepsWithPlanner = 0
 	this.lastState = discrete.State(this.mdp.GetTask().Obs.Ints.Index(obs.Ints()))
+	this.root = nil
 	this.Plan()
 	act = rlglue.NewAction(this.mdp.GetTask().Act.Ints.Values(this.GetAction()), []float64{}, []byte{})
 	this.lastAction = discrete.Action(this.mdp.GetTask().Act.Ints.Index(act.Ints()))
@@ -63,6 +123,10 @@ func (this *Agent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue
 	this.stepsWithPlanner++
 	nextState := discrete.State(this.mdp.GetTask().Obs.Ints.Index(obs.Ints()))
 	this.lastState = nextState
+	if this.root != nil {
+		this.mdpo = this.mdpo.Teleport(this.lastState)
+		this.root = this.s.Reroot(this.root, this.lastAction, this.mdpo)
+	}
 	this.Plan()
 	act = rlglue.NewAction(this.mdp.GetTask().Act.Ints.Values(this.GetAction()), []float64{}, []byte{})
 	this.lastAction = discrete.Action(this.mdp.GetTask().Act.Ints.Index(act.Ints()))
@@ -76,28 +140,56 @@ func (this *Agent) AgentMessage(message string) string {
 	tokens := strings.Split(message, " ", -1)
 	if tokens[0] == "seed" {
 		seed, _ := strconv.Atoi64(tokens[1])
-		rand.Seed(seed)
+		this.Seed(seed)
 	}
 	return ""
 }
 func (this *Agent) GetAction() (action uint64) {
 	if this.s == nil {
-		action = uint64(stat.NextRange(int64(this.mdp.GetTask().Act.Ints.Count())))
+		action = uint64(this.rng.Int63n(int64(this.mdp.GetTask().Act.Ints.Count())))
 		return
 	}
-	node := this.s.GetNode(this.stepsWithPlanner, this.mdpo)
+	if this.sparse != nil {
+		action = uint64(sparse.Best(this.qs))
+		this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: this.stepsWithPlanner, Action: action, Q: this.qs})
+		return
+	}
+	node := this.root
 	action = uint64(this.s.GetAction(node))
-	this.s.ClearLevel(this.stepsWithPlanner)
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: this.stepsWithPlanner, Action: action, Q: this.s.GetQs(node)})
 	return
 }
 func (this *Agent) Plan() {
-	this.mdpo = this.mdpo.Teleport(this.lastState)
-	root := this.s.GetNode(this.stepsWithPlanner, this.mdpo)
-	var expanded uint64
-	for i := 0; i < int(this.cfg.NumTrajectories); i++ {
-		expanded += this.s.RunTrajectory(root, this.cfg.Depth)
-		if this.cfg.Budget != 0 && expanded > this.cfg.Budget {
-			break
-		}
+	this.s.Telemetry = this.Telemetry
+	if this.sparse != nil {
+		this.planSparse()
+		return
 	}
+	if this.root == nil {
+		this.mdpo = this.mdpo.Teleport(this.lastState)
+		this.root = this.s.GetNode(this.stepsWithPlanner, this.mdpo)
+		this.s.SetRoot(this.root)
+	}
+	root := this.root
+	var lim plan.Limits
+	lim.Separated = this.cfg.StopSeparated
+	lim.Epsilon = this.cfg.Epsilon
+	if this.cfg.Deadline != 0 {
+		lim.Deadline = this.cfg.Deadline
+	} else {
+		lim.Trajectories = this.cfg.NumTrajectories
+		lim.Budget = this.cfg.Budget
+	}
+	this.LastPlan = this.s.Plan(root, this.cfg.Depth, lim)
+}
+
+//planSparse fills this.qs for the current state with a full sparse sampling tree.
+func (this *Agent) planSparse() {
+	start := time.Nanoseconds()
+	this.mdpo = this.mdpo.Teleport(this.lastState)
+	this.qs, this.LastPlan.Expanded = this.sparse.Qs(this.mdpo, this.cfg.Depth)
+	this.LastPlan.Trajectories = 0
+	this.LastPlan.Elapsed = time.Nanoseconds() - start
+	this.LastPlan.Stop = plan.StopNone
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlanningFinished, Expanded: this.LastPlan.Expanded, Elapsed: this.LastPlan.Elapsed})
 }
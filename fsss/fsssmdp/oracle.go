@@ -0,0 +1,64 @@
+package fsssmdp
+
+import (
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+)
+
+//Oracle is a discrete.MDPOracle that can also sample from a given generator,
+//so that a seeded fsss.Searcher plans the same way every run. Where T(s,a,.)
+//sums to less than one, the rest is the chance that the episode ends.
+type Oracle struct {
+	*discrete.MDPOracle
+	mdp discrete.MDP
+	s   discrete.State
+	//the draw fell in the probability T left over
+	terminal bool
+}
+
+func NewOracle(mdp discrete.MDP, s discrete.State) *Oracle {
+	return &Oracle{discrete.NewMDPOracle(mdp, s), mdp, s, false}
+}
+
+func (this *Oracle) Teleport(s discrete.State) *Oracle {
+	return &Oracle{this.MDPOracle.Teleport(s), this.mdp, s, false}
+}
+func (this *Oracle) Terminal() bool {
+	return this.terminal || this.MDPOracle.Terminal()
+}
+func (this *Oracle) Hashcode() uint64 {
+	if this.terminal {
+		return ^this.MDPOracle.Hashcode()
+	}
+	return this.MDPOracle.Hashcode()
+}
+func (this *Oracle) LessThan(other interface{}) bool {
+	oo := other.(*Oracle)
+	if this.terminal != oo.terminal {
+		return oo.terminal
+	}
+	return this.MDPOracle.LessThan(oo.MDPOracle)
+}
+func (this *Oracle) Next(a discrete.Action) (o discrete.Oracle, r float64) {
+	return this.next(a, rand.Float64())
+}
+func (this *Oracle) NextFrom(a discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64) {
+	return this.next(a, rng.Float64())
+}
+
+//the next state is the one whose share of T(s,a,.) u falls in, or the end of
+//the episode if u is past all of them
+func (this *Oracle) next(a discrete.Action, u float64) (o discrete.Oracle, r float64) {
+	r = this.mdp.R(this.s, a)
+	numStates := this.mdp.NumStates()
+	for i := uint64(0); i < numStates; i++ {
+		if u -= this.mdp.T(this.s, a, discrete.State(i)); u < 0 {
+			o = this.Teleport(discrete.State(i))
+			return
+		}
+	}
+	next := this.Teleport(this.s)
+	next.terminal = true
+	o = next
+	return
+}
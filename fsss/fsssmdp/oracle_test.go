@@ -0,0 +1,31 @@
+package fsssmdp
+
+import (
+	"rand"
+	"testing"
+	"go-glue.googlecode.com/hg/rlglue"
+	"github.com/skelterjohn/rlalg/bfs3"
+)
+
+const twoStateTask = "VERSION RL-Glue-3.0 PROBLEMTYPE episodic DISCOUNTFACTOR 0.9 OBSERVATIONS INTS (0 1) ACTIONS INTS (0 1) REWARDS (0 1.0)"
+
+func TestLeftoverProbabilityEnds(t *testing.T) {
+	task, err := rlglue.ParseTaskSpec(twoStateTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdp := bfs3.NewDirichletPrior(1)(task).(bfs3.MDPSampler).SampleMDP(rand.New(rand.NewSource(1))).(*bfs3.SampledMDP)
+	//half of (0,0) goes nowhere
+	mdp.Transitions[0][0] = []float64{0.25, 0.25}
+	o := NewOracle(mdp, 0)
+	if no, _ := o.next(0, 0.9); !no.Terminal() {
+		t.Errorf("a draw past T(0,0,.) did not end the episode")
+	}
+	no, _ := o.next(0, 0.3)
+	if no.Terminal() {
+		t.Errorf("a draw inside T(0,0,.) ended the episode")
+	}
+	if no.(*Oracle).s != 1 {
+		t.Errorf("a draw of 0.3 went to %v, not 1", no.(*Oracle).s)
+	}
+}
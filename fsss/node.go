@@ -3,8 +3,11 @@ package fsss
 import (
 	"sync"
 	"fmt"
+	"math"
 	"rand"
 	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/leaf"
+	"github.com/skelterjohn/rlalg/plan"
 )
 
 type Node struct {
@@ -12,10 +15,20 @@ type Node struct {
 	//used for synchronization
 	block sync.Mutex
 	o     discrete.Oracle
+	//the oracle this Node was made for, kept after expansion drops o
+	key discrete.Oracle
 	//is this Node a leaf?
 	leaf bool
+	//has a leaf.Evaluator set this leaf's bounds?
+	evaluated bool
 	//how many times each action has been attempted
 	totals []float64
+	//how many next states have been sampled for each action
+	samples []float64
+	//for adaptive width, the mean squared reward of each action, and how many
+	//of its latest samples in a row were next states it already had
+	r2      []float64
+	repeats []uint64
 	//current estimate for each action's reward
 	r []float64
 	//current estimate for each action's value bounds
@@ -26,8 +39,22 @@ type Node struct {
 	depth uint64
 	//number of trajectories running through this node
 	visits uint64
+	//number of trajectories inside this node right now
+	pending uint64
+	//the Searcher's clock when a trajectory last entered this node
+	lastVisit uint64
 	//the set of nexts, along with how many times they have occured
 	branches []map[*Node]float64
+	//the nexts of each action in the order they first occured, which is the
+	//order they are backed up in, so that sums come out the same every run
+	order [][]*Node
+	//every sample, and the tie-breaking in backups, draws from streams seeded
+	//from this
+	seed uint64
+	rng  *rand.Rand
+
+	//is it the minimizing player's turn here?
+	minimizing bool
 
 	//quickies
 	terminal              bool
@@ -41,65 +68,226 @@ func (n *Node) GetBranch(a int) map[*Node]float64 {
 func (n *Node) GetR(a int) float64 {
 	return n.r[a]
 }
+func (n *Node) GetNumActions() int {
+	return len(n.branches)
+}
+func (n *Node) GetQ(a int) (upper, lower float64) {
+	n.block.Lock()
+	defer n.block.Unlock()
+	return n.qupper[a], n.qlower[a]
+}
+func (n *Node) GetBestAction() discrete.Action {
+	return n.getBestAction()
+}
+func (n *Node) GetVisits() uint64 {
+	n.block.Lock()
+	defer n.block.Unlock()
+	return n.visits
+}
 
 func (n *Node) String() (res string) {
 	res = fmt.Sprintf("n{\no%v\n r{%v}\nvlower{%f} vupper{%f}\nqlower{%v}\nqupper{%v}\npi{%d}}", n.o, n.r, n.vlower, n.vupper, n.qlower, n.qupper, n.currentBestAction)
 	return
 }
 
+//GetOracle returns the oracle n was made for.
 func (n *Node) GetOracle() (o discrete.Oracle) {
-	return n.o
+	return n.key
 }
 func (n *Node) GetDepth() (depth uint64) {
 	return n.depth
 }
 func (n *Node) GetValue() (upper, lower float64) {
+	n.block.Lock()
+	defer n.block.Unlock()
 	return n.vupper, n.vlower
 }
 
-func newNode(s *Searcher, o discrete.Oracle) (n *Node) {
+//newNode makes the node for o, whose generator is seeded with seed unless o is
+//terminal.
+func newNode(s *Searcher, o discrete.Oracle, seed uint64) (n *Node) {
 	n = &Node{}
 	n.s = s
 	n.o = o
+	n.key = o
 	n.leaf = true
 	n.terminal = o.Terminal()
+	if to, ok := o.(TurnOracle); ok {
+		n.minimizing = to.Turn() == 1
+	}
 	if o.Terminal() {
 		//n.vupper, n.vlower = 0, 0
 	} else {
 		n.totals = make([]float64, s.NumActions)
+		n.samples = make([]float64, s.NumActions)
+		n.r2 = make([]float64, s.NumActions)
+		n.repeats = make([]uint64, s.NumActions)
 		n.r = make([]float64, s.NumActions)
 		n.qlower = make([]float64, s.NumActions)
 		n.qupper = make([]float64, s.NumActions)
 		n.branches = make([]map[*Node]float64, s.NumActions)
+		n.order = make([][]*Node, s.NumActions)
 		for a := uint64(0); a < s.NumActions; a++ {
-			n.qlower[a] = s.Vmin
-			n.qupper[a] = s.Vmax
 			n.branches[a] = make(map[*Node]float64)
 		}
 		n.currentMostUncertains = make([]*Node, s.NumActions)
+		n.seed = seed
+		n.rng = rand.New(plan.NewSource(int64(n.seed)))
 		n.vupper = s.Vmax
 		n.vlower = s.Vmin
+		if s.Cfg.Bounds != nil {
+			n.vlower, n.vupper = leaf.EvaluateFrom(s.Cfg.Bounds, o, n.rng)
+		}
+		n.resetQ()
 	}
 	return
 }
 
-//argmax over qvalues to find the best action
+//resetQ gives every action the bounds of one that has never been sampled: the
+//searcher's range, except that no action is worth more than the node's max, or
+//at a minimizing node less than its min. The caller holds the lock, or no
+//trajectories run.
+func (n *Node) resetQ() {
+	for a := range n.qupper {
+		n.qlower[a] = n.s.Vmin
+		n.qupper[a] = n.s.Vmax
+		if n.minimizing {
+			n.qlower[a] = n.vlower
+		} else {
+			n.qupper[a] = n.vupper
+		}
+	}
+}
+
+//argmax over qvalues to find the best action, or argmin at a minimizing node
 func (n *Node) getBestAction() (bestAction discrete.Action) {
 	n.block.Lock()
 	defer n.block.Unlock()
 	return n.currentBestAction
 }
 
-//argmax over the branch set to find the most uncertain next state
-func (n *Node) getMostUncertain(a discrete.Action) (unn *Node) {
+//argmax over the branch set to find the most uncertain next state, along with
+//the fraction of a's samples that went there. When trajectories run in
+//parallel, a next state that other trajectories are inside counts as less
+//uncertain (a virtual loss), so that they spread out.
+func (n *Node) getMostUncertain(a discrete.Action) (unn *Node, weight float64) {
 	n.block.Lock()
-	defer n.block.Unlock()
-	if n.currentMostUncertains[a] == nil {
+	unn = n.currentMostUncertains[a]
+	if unn == nil {
+		n.block.Unlock()
 		panic("mostUncertain is nil")
 	}
-	return n.currentMostUncertains[a]
+	samples := n.samples[a]
+	if !n.s.parallel() {
+		weight = n.branches[a][unn] / samples
+		n.block.Unlock()
+		return
+	}
+	nexts := n.order[a]
+	counts := make([]float64, len(nexts))
+	for i, nn := range nexts {
+		counts[i] = n.branches[a][nn]
+	}
+	n.block.Unlock()
+
+	mostUncertainty := -1.0
+	for i, nn := range nexts {
+		nn.block.Lock()
+		uncertainty := counts[i] * nn.getUncertainty() / float64(1+nn.pending)
+		nn.block.Unlock()
+		if uncertainty > mostUncertainty {
+			mostUncertainty, unn, weight = uncertainty, nn, counts[i]/samples
+		}
+	}
+	return
 }
 
+//separated reports whether n's best action's lower bound is above the upper
+//bound of every other available action, or at a minimizing node whether its
+//upper bound is below their lower bounds.
+func (n *Node) separated() bool {
+	n.block.Lock()
+	defer n.block.Unlock()
+	if n.terminal || n.leaf {
+		return false
+	}
+	af, haveActionFilter := n.key.(ActionFilter)
+	best := n.currentBestAction
+	for a := discrete.Action(0); a.Hashcode() < n.s.NumActions; a++ {
+		if a == best || (haveActionFilter && !af.ActionAvailable(a)) {
+			continue
+		}
+		if n.minimizing {
+			if n.qlower[a] <= n.qupper[best] {
+				return false
+			}
+		} else if n.qupper[a] >= n.qlower[best] {
+			return false
+		}
+	}
+	return true
+}
+
+//enter counts a trajectory into n, unless n has had its MaxVisits.
+func (n *Node) enter() bool {
+	n.block.Lock()
+	defer n.block.Unlock()
+	if n.s.Cfg.MaxVisits != 0 && n.s.Cfg.MaxVisits <= n.visits {
+		return false
+	}
+	n.visits++
+	n.pending++
+	n.s.lock.Lock()
+	n.s.clock++
+	n.lastVisit = n.s.clock
+	n.s.lock.Unlock()
+	return true
+}
+
+//collapsible reports whether n is expanded and all its children are leaves.
+//Only called while no trajectories run.
+func (n *Node) collapsible() bool {
+	if n.leaf || n.terminal {
+		return false
+	}
+	for _, nexts := range n.order {
+		for _, nn := range nexts {
+			if !nn.leaf {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//collapse turns n back into a leaf that keeps its value bounds, and reports how
+//many children it let go of. Only called while no trajectories run.
+func (n *Node) collapse() (children int) {
+	n.leaf = true
+	n.evaluated = true
+	n.o = n.key
+	for a := range n.branches {
+		children += len(n.branches[a])
+		n.branches[a] = make(map[*Node]float64)
+		n.order[a] = nil
+		n.currentMostUncertains[a] = nil
+		n.totals[a] = 0
+		n.samples[a] = 0
+		n.r2[a] = 0
+		n.repeats[a] = 0
+		n.r[a] = 0
+	}
+	n.resetQ()
+	return
+}
+
+func (n *Node) leave() {
+	n.block.Lock()
+	defer n.block.Unlock()
+	n.pending--
+}
+
+//the caller holds the lock
 func (n *Node) getUncertainty() (uncertainty float64) {
 	uncertainty = n.vupper - n.vlower
 	if n.s.Cfg.UseUncertaintyRate && n.visits != 0 {
@@ -115,21 +303,23 @@ func (n *Node) Backup() {
 	n.backup()
 	showoff = false
 }
+//a next state's bounds, read under its own lock
+type childValue struct {
+	vlower, vupper, uncertainty float64
+}
+
 func (n *Node) backup() {
+	if n.s.parallel() {
+		n.backupParallel()
+		return
+	}
 	n.block.Lock()
 	defer n.block.Unlock()
 	//fmt.Printf("+*Node.backup(%v)()\n", n.o.Hashcode())
 	//defer println("-*Node.backup")
-	n.currentBestAction = 0
-	//max it
-	n.vlower = n.s.Vmin
-	n.vupper = n.s.Vmin
-	//update the qs for each action
-
-	offset := uint64(rand.Intn(int(n.s.NumActions)))
-
-	af, haveActionFilter := n.o.(ActionFilter)
-
+	offset := n.resetValue()
+	//n.o is dropped once n is expanded, but the key is the same oracle
+	af, haveActionFilter := n.key.(ActionFilter)
 	for ao := uint64(0); ao < n.s.NumActions; ao++ {
 		a := discrete.Action((ao + offset) % n.s.NumActions)
 		avail := !haveActionFilter || af.ActionAvailable(a)
@@ -137,73 +327,324 @@ func (n *Node) backup() {
 		n.qupper[a] = 0
 		//E[V(s')] part
 		var mostUncertainty float64
-		for nn, count := range n.branches[a] {
-			weight := count / float64(n.s.Cfg.C)
-			wvupper := weight * nn.vupper
-			wvlower := weight * nn.vlower
-			n.qlower[a] += wvlower
-			n.qupper[a] += wvupper
-			uncertainty := nn.getUncertainty() * count
-			if avail && uncertainty >= mostUncertainty {
-				mostUncertainty, n.currentMostUncertains[a] = uncertainty, nn
-			}
+		for _, nn := range n.order[a] {
+			count := n.branches[a][nn]
+			v := childValue{nn.vlower, nn.vupper, nn.getUncertainty()}
+			n.addNext(a, nn, count, count/n.samples[a], v, avail, &mostUncertainty)
 		}
-		//gamma part
-		n.qlower[a] *= n.s.Gamma
-		n.qupper[a] *= n.s.Gamma
-		//R part
-		n.qlower[a] += n.r[a]
-		n.qupper[a] += n.r[a]
-		if avail {
-			//max operator
-			if n.qlower[a] > n.vlower {
-				n.vlower = n.qlower[a]
-			}
-			if n.qupper[a] > n.vupper {
-				n.vupper = n.qupper[a]
-				n.currentBestAction = a
+		n.finishAction(a, avail)
+	}
+}
+
+//backupParallel is backup for when trajectories run in parallel. The next
+//states' bounds are read before taking n's lock, so that no Node waits on
+//another while holding its own, and parallel backups can't deadlock when a
+//shallow search has cycles.
+func (n *Node) backupParallel() {
+	n.block.Lock()
+	order := make([][]*Node, len(n.order))
+	counts := make([][]float64, len(n.order))
+	samples := make([]float64, len(n.samples))
+	copy(samples, n.samples)
+	for a, nexts := range n.order {
+		//order only ever grows by append, so the first len(nexts) stay put
+		order[a] = nexts
+		counts[a] = make([]float64, len(nexts))
+		for i, nn := range nexts {
+			counts[a][i] = n.branches[a][nn]
+		}
+	}
+	n.block.Unlock()
+	values := make(map[*Node]childValue)
+	for _, nexts := range order {
+		for _, nn := range nexts {
+			if _, ok := values[nn]; !ok {
+				nn.block.Lock()
+				values[nn] = childValue{nn.vlower, nn.vupper, nn.getUncertainty()}
+				nn.block.Unlock()
 			}
 		}
 	}
+
+	n.block.Lock()
+	defer n.block.Unlock()
+	offset := n.resetValue()
+	af, haveActionFilter := n.key.(ActionFilter)
+	for ao := uint64(0); ao < n.s.NumActions; ao++ {
+		a := discrete.Action((ao + offset) % n.s.NumActions)
+		avail := !haveActionFilter || af.ActionAvailable(a)
+		n.qlower[a] = 0
+		n.qupper[a] = 0
+		var mostUncertainty float64
+		for i, nn := range order[a] {
+			n.addNext(a, nn, counts[a][i], counts[a][i]/samples[a], values[nn], avail, &mostUncertainty)
+		}
+		n.finishAction(a, avail)
+	}
+}
+
+//resetValue starts n's bounds where the max, or the min, of no actions would
+//be, and picks where the tie-breaking scan over the actions begins. The caller
+//holds the lock.
+func (n *Node) resetValue() (offset uint64) {
+	n.currentBestAction = 0
+	n.vlower = n.s.Vmin
+	n.vupper = n.s.Vmin
+	if n.minimizing {
+		n.vlower = n.s.Vmax
+		n.vupper = n.s.Vmax
+	}
+	return uint64(n.rng.Intn(int(n.s.NumActions)))
+}
+
+//addNext adds a next state's share of a's value bounds, and keeps track of a's
+//most uncertain next state. The caller holds the lock.
+func (n *Node) addNext(a discrete.Action, nn *Node, count, weight float64, v childValue, avail bool, mostUncertainty *float64) {
+	n.qlower[a] += weight * v.vlower
+	n.qupper[a] += weight * v.vupper
+	uncertainty := v.uncertainty * count
+	if avail && uncertainty >= *mostUncertainty {
+		*mostUncertainty, n.currentMostUncertains[a] = uncertainty, nn
+	}
+}
+
+//finishAction discounts a's next state values, adds its reward, and takes a
+//into n's max, or min. The caller holds the lock.
+func (n *Node) finishAction(a discrete.Action, avail bool) {
+	//gamma part
+	n.qlower[a] *= n.s.Gamma
+	n.qupper[a] *= n.s.Gamma
+	//R part
+	n.qlower[a] += n.r[a]
+	n.qupper[a] += n.r[a]
+	if avail && n.minimizing {
+		//min operator, choosing the action that could be lowest
+		if n.qupper[a] < n.vupper {
+			n.vupper = n.qupper[a]
+		}
+		if n.qlower[a] < n.vlower {
+			n.vlower = n.qlower[a]
+			n.currentBestAction = a
+		}
+	} else if avail {
+		//max operator
+		if n.qlower[a] > n.vlower {
+			n.vlower = n.qlower[a]
+		}
+		if n.qupper[a] > n.vupper {
+			n.vupper = n.qupper[a]
+			n.currentBestAction = a
+		}
+	}
+}
+
+//evaluate sets the bounds of a node where a trajectory stopped for depth: with
+//e if it is set and this is a leaf it hasn't seen, or else to 0 if zero is set.
+func (n *Node) evaluate(e leaf.Evaluator, zero bool) {
+	n.block.Lock()
+	defer n.block.Unlock()
+	if e != nil && n.leaf && !n.evaluated {
+		n.evaluated = true
+		n.vlower, n.vupper = leaf.EvaluateFrom(e, n.o, n.rng)
+	} else if zero {
+		n.vupper = 0
+		n.vlower = 0
+	}
+}
+
+//draw samples the i'th next state for a, from its own stream if n's oracle
+//takes one.
+func (n *Node) draw(a discrete.Action, i uint64) (no discrete.Oracle, r float64) {
+	if so, ok := n.o.(SourcedOracle); ok {
+		no, r = so.NextFrom(a, rand.New(plan.NewSource(plan.Mix(n.seed, a.Hashcode(), i))))
+	} else {
+		no, r = n.o.Next(a)
+	}
+	if no == nil {
+		panic("Next() -> nil")
+	}
+	return
+}
+
+//draw one more next state for a. The caller holds the lock.
+func (n *Node) sample(a discrete.Action, avail bool) {
+	no, r := n.draw(a, uint64(n.samples[a]))
+	n.insert(a, avail, no, r)
+}
+
+//add a drawn next state to a's branch. The caller holds the lock.
+func (n *Node) insert(a discrete.Action, avail bool, no discrete.Oracle, r float64) {
+	n.samples[a]++
+	n.r[a] += (r - n.r[a]) / n.samples[a]
+	n.r2[a] += (r*r - n.r2[a]) / n.samples[a]
+	//get the Node for no (next oracle)
+	nn := n.s.GetNode(n.depth+1, no)
+	count := n.branches[a][nn] + 1
+	n.branches[a][nn] = count
+	if count != 1 {
+		n.repeats[a]++
+	} else {
+		n.repeats[a] = 0
+		n.order[a] = append(n.order[a], nn)
+		n.s.lock.Lock()
+		n.s.numEdges++
+		n.s.lock.Unlock()
+	}
+
+	//the next backup picks the most uncertain
+	if avail && n.currentMostUncertains[a] == nil {
+		n.currentMostUncertains[a] = nn
+	}
+}
+
+//how many samples an action tried visits times should have under progressive
+//widening, at least 1 and at most C
+func (s *Searcher) widenLimit(visits float64) (limit float64) {
+	if visits < 1 {
+		visits = 1
+	}
+	limit = math.Ceil(s.Cfg.WideningK * math.Pow(visits, s.Cfg.WideningAlpha))
+	if limit < 1 {
+		limit = 1
+	}
+	if s.Cfg.C != 0 && limit > float64(s.Cfg.C) {
+		limit = float64(s.Cfg.C)
+	}
+	return
+}
+
+//settled reports whether adaptive width has sampled a enough. The caller holds
+//the lock.
+func (n *Node) settled(a discrete.Action) bool {
+	if n.samples[a] == 0 {
+		return false
+	}
+	if n.samples[a] >= float64(n.s.Cfg.C) {
+		return true
+	}
+	if n.repeats[a] < n.s.Cfg.AdaptiveRepeats {
+		return false
+	}
+	variance := n.r2[a] - n.r[a]*n.r[a]
+	return variance <= 0 || math.Sqrt(variance/n.samples[a]) <= n.s.Cfg.AdaptiveTolerance
 }
 
-func (n *Node) expand() (expanded bool) {
+//does the Searcher draw samples after expansion?
+func (s *Searcher) resamples() bool {
+	return s.Cfg.WideningK != 0 || s.Cfg.Adaptive
+}
+
+//widen draws another sample for a if it has been tried often enough to
+//deserve one, or, with adaptive width, if it hasn't settled. It reports how
+//many samples it drew.
+func (n *Node) widen(a discrete.Action) (drawn uint64) {
+	n.block.Lock()
+	defer n.block.Unlock()
+	n.totals[a]++
+	if !n.s.resamples() || n.o == nil {
+		return
+	}
+	af, haveActionFilter := n.o.(ActionFilter)
+	avail := !haveActionFilter || af.ActionAvailable(a)
+	if n.s.Cfg.WideningK != 0 {
+		for n.samples[a] < n.s.widenLimit(n.totals[a]) {
+			n.sample(a, avail)
+			drawn++
+		}
+	} else if !n.settled(a) {
+		n.sample(a, avail)
+		drawn++
+	}
+	return
+}
+
+//expand draws the first samples for every action: C of them, as many as
+//progressive widening allows for an untried action, or, with adaptive width,
+//until the action settles. It reports how many it drew, which is 0 if n was
+//already expanded.
+func (n *Node) expand() (drawn uint64) {
 	n.block.Lock()
 	defer n.block.Unlock()
 	//println("+*Node.expand")
 	//defer println("-*Node.expand")
-	if n.o.Terminal() {
-		return false
+	if !n.leaf || n.o.Terminal() {
+		return 0
 	}
-	expanded = true
 	n.leaf = false
 
 	af, haveActionFilter := n.o.(ActionFilter)
 
+	width := float64(n.s.Cfg.C)
+	if n.s.Cfg.WideningK != 0 {
+		width = n.s.widenLimit(0)
+	}
+
+	avail := make([]bool, n.s.NumActions)
 	for a := discrete.Action(0); a.Hashcode() < n.s.NumActions; a++ {
-		avail := !haveActionFilter || af.ActionAvailable(a)
+		avail[a] = !haveActionFilter || af.ActionAvailable(a)
 		n.r[a] = 0
-		var mostUncertainty float64
-		for i := uint64(0); i < n.s.Cfg.C; i++ {
-			no, r := n.o.Next(a)
-			if no == nil {
-				panic("Next() -> nil")
+	}
+	if n.s.Cfg.Adaptive && n.s.Cfg.WideningK == 0 {
+		//each sample decides whether there is another, so they are drawn in turn
+		for a := discrete.Action(0); a.Hashcode() < n.s.NumActions; a++ {
+			for !n.settled(a) {
+				n.sample(a, avail[a])
+				drawn++
 			}
-			n.r[a] += r
-			//get the Node for no (next oracle)
-			nn := n.s.GetNode(n.depth+1, no)
-			count := n.branches[a][nn] + 1
-			n.branches[a][nn] = count
-
-			uncertainty := count * nn.getUncertainty()
-			if avail && uncertainty >= mostUncertainty {
-				mostUncertainty, n.currentMostUncertains[a] = uncertainty, nn
+		}
+	} else if n.drawsConcurrently() {
+		drawn = n.drawConcurrently(uint64(width), avail)
+	} else {
+		for a := discrete.Action(0); a.Hashcode() < n.s.NumActions; a++ {
+			for n.samples[a] < width {
+				n.sample(a, avail[a])
+				drawn++
 			}
 		}
-		n.r[a] /= float64(n.s.Cfg.C)
 	}
 
-	n.o = nil
+	//widening and adaptive width need the oracle for later samples
+	if !n.s.resamples() {
+		n.o = nil
+	}
+
+	return
+}
+
+//drawsConcurrently reports whether a reproducible search can draw n's samples
+//from several goroutines: only a SourcedOracle draws each sample from a stream
+//of its own, and an Oracle may not be safe to call from more than one.
+func (n *Node) drawsConcurrently() bool {
+	_, sourced := n.o.(SourcedOracle)
+	return n.s.Cfg.Reproducible && n.s.Cfg.Workers > 1 && sourced
+}
 
+//drawConcurrently draws the first width samples of every action from
+//Cfg.Workers goroutines, then adds them in order, so the tree comes out the
+//same as if they had been drawn one at a time. The caller holds the lock.
+func (n *Node) drawConcurrently(width uint64, avail []bool) (drawn uint64) {
+	drawn = n.s.NumActions * width
+	nexts := make([]discrete.Oracle, drawn)
+	rs := make([]float64, drawn)
+	jobs := make(chan uint64)
+	done := make(chan bool)
+	for w := 0; w < n.s.Cfg.Workers; w++ {
+		go func() {
+			for j := range jobs {
+				nexts[j], rs[j] = n.draw(discrete.Action(j/width), j%width)
+			}
+			done <- true
+		}()
+	}
+	for j := uint64(0); j < drawn; j++ {
+		jobs <- j
+	}
+	close(jobs)
+	for w := 0; w < n.s.Cfg.Workers; w++ {
+		<-done
+	}
+	for j := uint64(0); j < drawn; j++ {
+		n.insert(discrete.Action(j/width), avail[j/width], nexts[j], rs[j])
+	}
 	return
 }
@@ -0,0 +1,36 @@
+package fsss
+
+import (
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//A SourcedOracle can draw its next states from a given generator instead of a
+//shared one. A Searcher gives each sample its own generator, seeded from the
+//node and the sample's place there, so that a search is reproducible no matter
+//which order samples are drawn in.
+type SourcedOracle interface {
+	NextFrom(action discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64)
+}
+
+//Seed makes the Searcher's nodes draw from a source seeded with seed.
+func (s *Searcher) Seed(seed int64) {
+	s.SetSource(plan.NewSource(seed))
+}
+
+//SetSource makes the Searcher's nodes draw from src. Each new node takes its own
+//seed from src, so src is only used while the tree grows.
+func (s *Searcher) SetSource(src rand.Source) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src = src
+}
+
+//a seed for a new node. The caller holds s.lock.
+func (s *Searcher) nextSeed() uint64 {
+	if s.src == nil {
+		s.src = plan.NewSource(s.Cfg.Seed)
+	}
+	return uint64(s.src.Int63())
+}
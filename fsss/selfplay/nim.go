@@ -0,0 +1,47 @@
+package selfplay
+
+import (
+	"go-glue.googlecode.com/hg/rltools/discrete"
+)
+
+//Nim is an example Game: the players take turns removing stones from a pile,
+//action a taking a+1 of them, and whoever takes the last one wins. Only
+//actions that take at most what is left are available, and taking more than
+//that just empties the pile.
+type Nim struct {
+	Stones uint64
+	//the player to move
+	ToMove int
+}
+
+func (this Nim) Hashcode() uint64 {
+	return this.Stones<<1 | uint64(this.ToMove)
+}
+func (this Nim) LessThan(other interface{}) bool {
+	return this.Hashcode() < other.(Nim).Hashcode()
+}
+func (this Nim) Terminal() bool {
+	return this.Stones == 0
+}
+func (this Nim) Turn() int {
+	return this.ToMove
+}
+func (this Nim) ActionAvailable(a discrete.Action) bool {
+	return a.Hashcode()+1 <= this.Stones
+}
+func (this Nim) Next(a discrete.Action) (o discrete.Oracle, r float64) {
+	taken := a.Hashcode() + 1
+	if taken > this.Stones {
+		taken = this.Stones
+	}
+	next := Nim{this.Stones - taken, 1 - this.ToMove}
+	if next.Terminal() {
+		//player 0's reward
+		r = 1
+		if this.ToMove == 1 {
+			r = -1
+		}
+	}
+	o = next
+	return
+}
@@ -0,0 +1,108 @@
+package selfplay
+
+import (
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//A Game is a position in a two-player zero-sum game. Its rewards are player 0's.
+type Game interface {
+	discrete.Oracle
+	fsss.TurnOracle
+}
+
+type Config struct {
+	//how deep, and for how long, each move is planned
+	Depth  uint64
+	Limits plan.Limits
+	//moves before a game is called. 0 is no limit.
+	MaxMoves uint64
+	//how many games a Match plays
+	Games uint64
+	//seeds the draws of the game's moves
+	Seed int64
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.Depth = 10
+	cfg.Limits.Trajectories = 100
+	cfg.Limits.Budget = 1000
+	cfg.MaxMoves = 0
+	cfg.Games = 10
+	cfg.Seed = 1
+	return
+}
+
+type Result struct {
+	Moves uint64
+	//the sum of player 0's rewards
+	Return  float64
+	Actions []discrete.Action
+	Plans   []plan.Stats
+}
+
+//next draws a game's chance moves from rng, if it is an fsss.SourcedOracle
+func next(o Game, a discrete.Action, rng *rand.Rand) (no Game, r float64) {
+	var nextOracle discrete.Oracle
+	if so, ok := o.(fsss.SourcedOracle); ok {
+		nextOracle, r = so.NextFrom(a, rng)
+	} else {
+		nextOracle, r = o.Next(a)
+	}
+	no = nextOracle.(Game)
+	return
+}
+
+//Play plays one game from start, where players[i] moves whenever it is player
+//i's turn. Each move is planned from the searcher's depth 0 node for the
+//position, and SetRoot drops the rest of its tree, so only a Shallow searcher,
+//whose nodes are all at depth 0, carries planning over from earlier moves. The
+//searchers must share their Vmin and Vmax, which bound player 0's return.
+func Play(start Game, players [2]*fsss.Searcher, cfg Config, rng *rand.Rand) (res Result) {
+	o := start
+	for !o.Terminal() && (cfg.MaxMoves == 0 || res.Moves < cfg.MaxMoves) {
+		s := players[o.Turn()]
+		root := s.GetNode(0, o)
+		s.SetRoot(root)
+		res.Plans = append(res.Plans, s.Plan(root, cfg.Depth, cfg.Limits))
+		a := s.GetAction(root)
+		var r float64
+		o, r = next(o, a, rng)
+		res.Return += r
+		res.Actions = append(res.Actions, a)
+		res.Moves++
+	}
+	return
+}
+
+type Score struct {
+	//games each searcher won, from either seat
+	Wins  [2]uint64
+	Draws uint64
+	//what each game's player 0 got
+	Results []Result
+}
+
+//Match plays cfg.Games games from start between two searchers, which swap seats
+//after every game. Player 0 wins a game with a positive return, and player 1
+//one with a negative return.
+func Match(start Game, searchers [2]*fsss.Searcher, cfg Config) (score Score) {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	for g := uint64(0); g < cfg.Games; g++ {
+		first := int(g % 2)
+		players := [2]*fsss.Searcher{searchers[first], searchers[1-first]}
+		res := Play(start, players, cfg, rng)
+		score.Results = append(score.Results, res)
+		switch {
+		case res.Return > 0:
+			score.Wins[first]++
+		case res.Return < 0:
+			score.Wins[1-first]++
+		default:
+			score.Draws++
+		}
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/fsss/selfplay"
+)
+
+type Config struct {
+	//nim: the players take turns removing 1 to Take stones from a pile of
+	//Stones, and whoever takes the last one wins
+	Stones uint64
+	Take   uint64
+	Play   selfplay.Config
+	//the two searchers
+	A, B fsss.Config
+}
+
+func newSearcher(cfg fsss.Config, take uint64) (s *fsss.Searcher) {
+	s = fsss.New()
+	s.Cfg = cfg
+	s.NumActions = take
+	s.Gamma = 1
+	s.Vmin, s.Vmax = -1, 1
+	return
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.Stones = 21
+	config.Take = 3
+	config.Play = selfplay.ConfigDefault()
+	config.A = fsss.ConfigDefault()
+	config.B = fsss.ConfigDefault()
+	argcfg.LoadArgs(&config)
+	for _, cfg := range []fsss.Config{config.A, config.B} {
+		if err := cfg.Check(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	searchers := [2]*fsss.Searcher{newSearcher(config.A, config.Take), newSearcher(config.B, config.Take)}
+	score := selfplay.Match(selfplay.Nim{Stones: config.Stones}, searchers, config.Play)
+	for g, res := range score.Results {
+		fmt.Printf("game %d: %d moves, return %v, actions %v\n", g, res.Moves, res.Return, res.Actions)
+	}
+	fmt.Printf("A won %d, B won %d, %d drawn\n", score.Wins[0], score.Wins[1], score.Draws)
+}
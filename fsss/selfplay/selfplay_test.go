@@ -0,0 +1,107 @@
+package selfplay
+
+import (
+	"testing"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+func newSearcher() (s *fsss.Searcher) {
+	s = fsss.New()
+	s.Cfg = fsss.ConfigDefault()
+	s.Cfg.C = 1
+	s.NumActions = 3
+	s.Gamma = 1
+	s.Vmin, s.Vmax = -1, 1
+	return
+}
+
+func TestNimTakesWhatIsLeft(t *testing.T) {
+	//taking 3 of 2 stones empties the pile instead of wrapping around
+	o, r := Nim{2, 0}.Next(discrete.Action(2))
+	if next := o.(Nim); next.Stones != 0 || next.ToMove != 1 || r != 1 {
+		t.Errorf("got %v with reward %v, want an empty pile won by player 0", next, r)
+	}
+}
+
+func TestMinBackup(t *testing.T) {
+	//whoever is to move can take both stones and win
+	for turn, want := range []float64{1, -1} {
+		s := newSearcher()
+		root := s.GetNode(0, Nim{2, turn})
+		s.SetRoot(root)
+		s.Plan(root, 4, plan.Limits{Trajectories: 100})
+		vupper, vlower := root.GetValue()
+		if vupper != want || vlower != want {
+			t.Errorf("turn %d: bounds [%v, %v], want %v", turn, vlower, vupper, want)
+		}
+		if a := s.GetAction(root); a != 1 {
+			t.Errorf("turn %d: took %d stones, not 2", turn, a+1)
+		}
+	}
+}
+
+func TestMatchScoresBySeat(t *testing.T) {
+	//5 stones is a win for whoever moves first
+	cfg := ConfigDefault()
+	cfg.Games = 2
+	cfg.Depth = 6
+	cfg.MaxMoves = 10
+	cfg.Limits = plan.Limits{Trajectories: 1000}
+	score := Match(Nim{5, 0}, [2]*fsss.Searcher{newSearcher(), newSearcher()}, cfg)
+	for g, res := range score.Results {
+		if res.Return <= 0 {
+			t.Errorf("game %d: player 0 got %v with actions %v", g, res.Return, res.Actions)
+		}
+	}
+	if score.Wins != [2]uint64{1, 1} || score.Draws != 0 {
+		t.Errorf("wins %v, draws %d: each searcher should win the game it started", score.Wins, score.Draws)
+	}
+}
+
+//qOutsideV reports the first node below root where an action is better for the
+//player to move than the node's value allows
+func qOutsideV(s *fsss.Searcher, root *fsss.Node) (bad *fsss.Node) {
+	seen := map[*fsss.Node]bool{root: true}
+	for nodes := []*fsss.Node{root}; len(nodes) != 0; nodes = nodes[1:] {
+		n := nodes[0]
+		vupper, vlower := n.GetValue()
+		lower, upper := s.GetQBounds(n)
+		//the root is the minimizer's, and turns alternate
+		minimizing := n.GetDepth()%2 == 0
+		for a := range lower {
+			if minimizing && lower[a] < vlower || !minimizing && upper[a] > vupper {
+				return n
+			}
+			for nn := range n.GetBranch(a) {
+				if !seen[nn] {
+					seen[nn] = true
+					nodes = append(nodes, nn)
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestEvictionKeepsQInsideV(t *testing.T) {
+	//with 6 stones whoever is to move takes 2 and wins
+	s := newSearcher()
+	s.Cfg.MaxNodes = 8
+	root := s.GetNode(0, Nim{6, 1})
+	s.SetRoot(root)
+	for i := 0; i < 100; i++ {
+		s.RunTrajectory(root, 8)
+		s.Evict(root)
+		if bad := qOutsideV(s, root); bad != nil {
+			lower, upper := s.GetQBounds(bad)
+			vupper, vlower := bad.GetValue()
+			t.Fatalf("trajectory %d: node at depth %d has action bounds %v to %v outside [%v, %v]", i, bad.GetDepth(), lower, upper, vlower, vupper)
+		}
+	}
+	vupper, vlower := root.GetValue()
+	if vupper != -1 || vlower != -1 {
+		t.Errorf("bounds [%v, %v], want -1", vlower, vupper)
+	}
+}
@@ -0,0 +1,62 @@
+package leaf
+
+import (
+	"math"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/vi"
+)
+
+//The Evaluators here give sound bounds, so they can start an fsss node's value
+//bounds off as fsss.Config.Bounds. So can a Heuristic that is known to be sound.
+
+//RewardRange bounds the value by the most and least reward per step, with 0 in
+//between since an episode may end. Terminal states are worth 0.
+type RewardRange struct {
+	Vmin, Vmax float64
+}
+
+func NewRewardRange(task *rlglue.TaskSpec, gamma float64) (this *RewardRange) {
+	this = new(RewardRange)
+	this.Vmin = math.Fmin(0, task.Reward.Min/(1-gamma))
+	this.Vmax = math.Fmax(0, task.Reward.Max/(1-gamma))
+	return
+}
+
+func (this *RewardRange) Evaluate(o discrete.Oracle) (lower, upper float64) {
+	if o.Terminal() {
+		return
+	}
+	return this.Vmin, this.Vmax
+}
+
+//Relaxed bounds the value with the solutions of two relaxed models, one that
+//is optimistic everywhere for the upper bound and one that is pessimistic
+//everywhere for the lower. State maps the oracle to their state.
+type Relaxed struct {
+	Upper, Lower *discrete.QTable
+	//how far value iteration may have stopped short, added to either side
+	Slack float64
+	State func(o discrete.Oracle) discrete.State
+}
+
+//SolveRelaxed runs value iteration to epsilon on both models.
+func SolveRelaxed(optimistic, pessimistic discrete.MDP, epsilon float64, state func(o discrete.Oracle) discrete.State) (this *Relaxed) {
+	this = new(Relaxed)
+	this.Upper = discrete.NewQTable(optimistic.NumStates(), optimistic.NumActions())
+	vi.ValueIteration(this.Upper, optimistic, epsilon)
+	this.Lower = discrete.NewQTable(pessimistic.NumStates(), pessimistic.NumActions())
+	vi.ValueIteration(this.Lower, pessimistic, epsilon)
+	gamma := math.Fmax(optimistic.GetGamma(), pessimistic.GetGamma())
+	this.Slack = epsilon * gamma / (1 - gamma)
+	this.State = state
+	return
+}
+
+func (this *Relaxed) Evaluate(o discrete.Oracle) (lower, upper float64) {
+	if o.Terminal() {
+		return
+	}
+	s := this.State(o)
+	return this.Lower.V(s) - this.Slack, this.Upper.V(s) + this.Slack
+}
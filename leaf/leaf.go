@@ -0,0 +1,117 @@
+package leaf
+
+import (
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+)
+
+//An Evaluator values the state an oracle is in when a search stops there. Point
+//estimates return the same value for both bounds.
+type Evaluator interface {
+	Evaluate(o discrete.Oracle) (lower, upper float64)
+}
+
+type actionFilter interface {
+	ActionAvailable(action discrete.Action) bool
+}
+
+//A SourcedEvaluator can draw whatever randomness it needs from a given generator
+//instead of the shared one, so that a seeded search stays reproducible.
+type SourcedEvaluator interface {
+	EvaluateFrom(o discrete.Oracle, rng *rand.Rand) (lower, upper float64)
+}
+
+//EvaluateFrom evaluates o with e, drawing from rng if e takes a generator.
+func EvaluateFrom(e Evaluator, o discrete.Oracle, rng *rand.Rand) (lower, upper float64) {
+	if se, ok := e.(SourcedEvaluator); ok && rng != nil {
+		return se.EvaluateFrom(o, rng)
+	}
+	return e.Evaluate(o)
+}
+
+//the fsss.SourcedOracle interface
+type sourcedOracle interface {
+	NextFrom(action discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64)
+}
+
+//a uniformly random action, among the available ones if o filters them, drawn
+//from rng, or from the shared generator if rng is nil. ok is false if no action
+//is available.
+func RandomAction(o discrete.Oracle, numActions uint64, rng *rand.Rand) (a discrete.Action, ok bool) {
+	af, haveActionFilter := o.(actionFilter)
+	var actions []discrete.Action
+	for a = 0; a.Hashcode() < numActions; a++ {
+		if !haveActionFilter || af.ActionAvailable(a) {
+			actions = append(actions, a)
+		}
+	}
+	if len(actions) == 0 {
+		return
+	}
+	if rng == nil {
+		return actions[rand.Intn(len(actions))], true
+	}
+	return actions[rng.Intn(len(actions))], true
+}
+
+//Rollout is the discounted return of following Policy from the oracle for
+//Length steps. A nil Policy acts uniformly at random, and stops early where no
+//action is available.
+type Rollout struct {
+	NumActions	uint64
+	Gamma		float64
+	Length		uint64
+	Policy		func(o discrete.Oracle) discrete.Action
+}
+
+func (this *Rollout) Evaluate(o discrete.Oracle) (lower, upper float64) {
+	return this.EvaluateFrom(o, nil)
+}
+
+//EvaluateFrom draws the random actions, and the next states of oracles that
+//take a generator, from rng.
+func (this *Rollout) EvaluateFrom(o discrete.Oracle, rng *rand.Rand) (lower, upper float64) {
+	discount := 1.0
+	for i := uint64(0); i < this.Length && !o.Terminal(); i++ {
+		var a discrete.Action
+		var ok bool
+		if this.Policy != nil {
+			a = this.Policy(o)
+		} else if a, ok = RandomAction(o, this.NumActions, rng); !ok {
+			break
+		}
+		var r float64
+		if so, sourced := o.(sourcedOracle); sourced && rng != nil {
+			o, r = so.NextFrom(a, rng)
+		} else {
+			o, r = o.Next(a)
+		}
+		lower += discount * r
+		discount *= this.Gamma
+	}
+	upper = lower
+	return
+}
+
+//QTable looks the value up in a table, such as one value iteration computed
+//for an abstract model. State maps the oracle to the table's state.
+type QTable struct {
+	QT	*discrete.QTable
+	State	func(o discrete.Oracle) discrete.State
+}
+
+func (this *QTable) Evaluate(o discrete.Oracle) (lower, upper float64) {
+	if o.Terminal() {
+		return
+	}
+	lower = this.QT.V(this.State(o))
+	upper = lower
+	return
+}
+
+//Heuristic is a user function giving bounds on the value.
+type Heuristic func(o discrete.Oracle) (lower, upper float64)
+
+func (this Heuristic) Evaluate(o discrete.Oracle) (lower, upper float64) {
+	return this(o)
+}
@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Limits bound the planning for one decision. Zero fields are no limit.
+type Limits struct {
+	Trajectories uint64
+	//leaves expanded
+	Budget uint64
+	//wall-clock nanoseconds
+	Deadline int64
+	//how many goroutines run trajectories at once; 0 and 1 are both sequential
+	Workers int
+	//stop early once the root's best action is provably best
+	Separated bool
+	//stop early once the root's value bounds are closer than this
+	Epsilon float64
+}
+
+//Stop says why planning stopped.
+type Stop int
+
+const (
+	//there were no limits, so nothing ran
+	StopNone Stop = iota
+	StopTrajectories
+	StopBudget
+	StopDeadline
+	StopSeparated
+	StopGap
+)
+
+var stopNames = []string{
+	"none",
+	"trajectories",
+	"budget",
+	"deadline",
+	"separated",
+	"gap",
+}
+
+func (stop Stop) String() string {
+	if int(stop) < len(stopNames) {
+		return stopNames[stop]
+	}
+	return fmt.Sprintf("stop-%d", int(stop))
+}
+
+type Stats struct {
+	Trajectories uint64
+	Expanded     uint64
+	//wall-clock nanoseconds spent
+	Elapsed int64
+	Stop    Stop
+}
+
+//Limited reports whether lim would ever end planning. Searchers run nothing
+//when it doesn't.
+func (lim Limits) Limited() bool {
+	return lim.Trajectories != 0 || lim.Budget != 0 || lim.Deadline != 0
+}
+
+//Exhausted checks whether stats, for planning that began at start, have used up
+//one of lim's limits.
+func (lim Limits) Exhausted(stats Stats, start int64) Stop {
+	if lim.Trajectories != 0 && stats.Trajectories >= lim.Trajectories {
+		return StopTrajectories
+	}
+	if lim.Budget != 0 && stats.Expanded > lim.Budget {
+		return StopBudget
+	}
+	if lim.Deadline != 0 && time.Nanoseconds()-start >= lim.Deadline {
+		return StopDeadline
+	}
+	return StopNone
+}
+
+//A Trajectory runs one trajectory and says how many leaves it expanded.
+type Trajectory func() (expanded uint64)
+
+//A Convergence gives a reason to stop before the limits are reached, or StopNone.
+type Convergence func() Stop
+
+//Run runs trajectories one after another until converged gives a reason to stop
+//or one of lim's limits is reached. converged may be nil.
+func Run(lim Limits, trajectory Trajectory, converged Convergence) (stats Stats) {
+	start := time.Nanoseconds()
+	if lim.Limited() {
+		for {
+			if converged != nil {
+				if stats.Stop = converged(); stats.Stop != StopNone {
+					break
+				}
+			}
+			stats.Expanded += trajectory()
+			stats.Trajectories++
+			if stats.Stop = lim.Exhausted(stats, start); stats.Stop != StopNone {
+				break
+			}
+		}
+	}
+	stats.Elapsed = time.Nanoseconds() - start
+	return
+}
+
+//RunParallel is Run with lim.Workers goroutines calling trajectory at once.
+//Budget and Deadline are checked as each trajectory finishes, so the
+//trajectories still running when one is reached may overshoot it.
+func RunParallel(lim Limits, trajectory Trajectory, converged Convergence) (stats Stats) {
+	start := time.Nanoseconds()
+	if !lim.Limited() {
+		return
+	}
+	var lock sync.Mutex
+	done := false
+	finished := make(chan bool)
+	for w := 0; w < lim.Workers; w++ {
+		go func() {
+			for {
+				lock.Lock()
+				if !done && converged != nil {
+					stats.Stop = converged()
+					done = stats.Stop != StopNone
+				}
+				if done {
+					lock.Unlock()
+					break
+				}
+				stats.Trajectories++
+				if lim.Trajectories != 0 && stats.Trajectories >= lim.Trajectories {
+					done, stats.Stop = true, StopTrajectories
+				}
+				lock.Unlock()
+
+				expanded := trajectory()
+
+				lock.Lock()
+				stats.Expanded += expanded
+				if !done {
+					stats.Stop = lim.Exhausted(stats, start)
+					done = stats.Stop != StopNone
+				}
+				lock.Unlock()
+			}
+			finished <- true
+		}()
+	}
+	for w := 0; w < lim.Workers; w++ {
+		<-finished
+	}
+	stats.Elapsed = time.Nanoseconds() - start
+	return
+}
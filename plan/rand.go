@@ -0,0 +1,39 @@
+package plan
+
+import (
+	"rand"
+)
+
+//splitMix is a small, fast rand.Source, cheap enough that every node and every
+//sample of a search can have its own.
+type splitMix struct {
+	state uint64
+}
+
+func (this *splitMix) Seed(seed int64) {
+	this.state = uint64(seed)
+}
+func (this *splitMix) next() uint64 {
+	this.state += 0x9e3779b97f4a7c15
+	z := this.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+func (this *splitMix) Int63() int64 {
+	return int64(this.next() >> 1)
+}
+
+//NewSource makes the kind of rand.Source the searchers use.
+func NewSource(seed int64) rand.Source {
+	return &splitMix{uint64(seed)}
+}
+
+//Mix derives the seed of an independent stream from seed and the indices.
+func Mix(seed uint64, indices ...uint64) int64 {
+	sm := splitMix{seed}
+	for _, i := range indices {
+		sm.state ^= sm.next() + i
+	}
+	return sm.Int63()
+}
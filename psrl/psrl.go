@@ -0,0 +1,117 @@
+package psrl
+
+import (
+	"strings"
+	"strconv"
+	"rand"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlbayes"
+	"github.com/skelterjohn/rlalg/bfs3"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/vi"
+)
+
+type Config struct {
+	//draw a new MDP every this many steps, or only at the start of each episode if 0
+	Resample	uint64
+	Epsilon		float64
+	//seeds the MDP draws, unless the agent gets a seed message
+	Seed	int64
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.Resample = 0
+	cfg.Epsilon = 0.1
+	cfg.Seed = 1
+	return
+}
+
+//Agent is posterior sampling for reinforcement learning: it draws an MDP from
+//its belief, solves it, and acts greedily in it until the next draw. The belief
+//from the prior must implement bfs3.MDPSampler.
+type Agent struct {
+	task		*rlglue.TaskSpec
+	prior		bfs3.Prior
+	belief		bayes.BeliefState
+	mdp		discrete.MDP
+	qt		*discrete.QTable
+	lastAction	discrete.Action
+	steps		uint64
+	Cfg		Config
+	Telemetry	telemetry.Sink
+	rng		*rand.Rand
+}
+
+func New(cfg Config, prior bfs3.Prior) (this *Agent) {
+	this = new(Agent)
+	this.Cfg = cfg
+	this.prior = prior
+	this.Telemetry = telemetry.Nop{}
+	this.rng = rand.New(rand.NewSource(cfg.Seed))
+	return
+}
+func (this *Agent) GetBelief() bayes.BeliefState {
+	return this.belief
+}
+func (this *Agent) AgentInit(taskString string) {
+	this.task, _ = rlglue.ParseTaskSpec(taskString)
+	if this.task.DiscountFactor == 1 {
+		this.task.DiscountFactor = 0.95
+	}
+	this.belief = this.prior(this.task)
+	if _, ok := this.belief.(bfs3.MDPSampler); !ok {
+		panic("psrl: the prior's belief cannot sample MDPs")
+	}
+	this.qt = discrete.NewQTable(this.task.Obs.Ints.Count(), this.task.Act.Ints.Count())
+}
+//Resample draws a new MDP from the belief and solves it.
+func (this *Agent) Resample() {
+	this.mdp = this.belief.(bfs3.MDPSampler).SampleMDP(this.rng)
+	vi.ValueIteration(this.qt, this.mdp, this.Cfg.Epsilon)
+	this.steps = 0
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlannerReset})
+}
+func (this *Agent) chooseAction() (act rlglue.Action) {
+	s := this.belief.GetState()
+	this.lastAction = this.qt.Pi(s)
+	q := make([]float64, this.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = this.qt.Q(s, discrete.Action(a))
+	}
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Step: this.steps, Action: this.lastAction.Hashcode(), Q: q})
+	act = rlglue.NewAction(this.task.Act.Ints.Values(this.lastAction.Hashcode()), []float64{}, []byte{})
+	return
+}
+func (this *Agent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	if s != this.belief.GetState() {
+		this.belief.Teleport(s)
+	}
+	this.Resample()
+	act = this.chooseAction()
+	return
+}
+func (this *Agent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	s := discrete.State(this.task.Obs.Ints.Index(obs.Ints()))
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, s, false, reward)
+	this.steps++
+	if this.Cfg.Resample != 0 && this.steps >= this.Cfg.Resample {
+		this.Resample()
+	}
+	act = this.chooseAction()
+	return
+}
+func (this *Agent) AgentEnd(reward float64) {
+	this.belief, _ = bfs3.Observe(this.belief, this.lastAction, 0, true, reward)
+}
+func (this *Agent) AgentCleanup() {
+}
+func (this *Agent) AgentMessage(message string) string {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		this.rng = rand.New(rand.NewSource(seed))
+	}
+	return ""
+}
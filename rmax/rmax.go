@@ -3,6 +3,7 @@ package rmax
 import (
 	"go-glue.googlecode.com/hg/rlglue"
 	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/telemetry"
 	"github.com/skelterjohn/rlalg/vi"
 )
 
@@ -113,11 +114,13 @@ type RmaxAgent struct {
 	lastState	discrete.State
 	lastAction	discrete.Action
 	Cfg		RmaxConfig
+	Telemetry	telemetry.Sink
 }
 
 func NewRmaxAgent(Cfg RmaxConfig) (ra *RmaxAgent) {
 	ra = new(RmaxAgent)
 	ra.Cfg = Cfg
+	ra.Telemetry = telemetry.Nop{}
 	return
 }
 func (ra *RmaxAgent) AgentInit(taskString string) {
@@ -127,7 +130,7 @@ func (ra *RmaxAgent) AgentInit(taskString string) {
 }
 func (ra *RmaxAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
 	ra.lastState = discrete.State(ra.task.Obs.Ints.Index(obs.Ints()))
-	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.qt.Pi(ra.lastState).Hashcode()), []float64{}, []byte{})
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
 	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
 	return
 }
@@ -138,7 +141,7 @@ func (ra *RmaxAgent) AgentStep(reward float64, obs rlglue.Observation) (act rlgl
 		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
 	}
 	ra.lastState = nextState
-	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.qt.Pi(ra.lastState).Hashcode()), []float64{}, []byte{})
+	act = rlglue.NewAction(ra.task.Act.Ints.Values(ra.chooseAction().Hashcode()), []float64{}, []byte{})
 	ra.lastAction = discrete.Action(ra.task.Act.Ints.Index(act.Ints()))
 	return
 }
@@ -148,6 +151,15 @@ func (ra *RmaxAgent) AgentEnd(reward float64) {
 		vi.ValueIteration(ra.qt, ra.rmdp, ra.Cfg.Epsilon)
 	}
 }
+func (ra *RmaxAgent) chooseAction() (action discrete.Action) {
+	action = ra.qt.Pi(ra.lastState)
+	q := make([]float64, ra.task.Act.Ints.Count())
+	for a := range q {
+		q[a] = ra.qt.Q(ra.lastState, discrete.Action(a))
+	}
+	ra.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: action.Hashcode(), Q: q})
+	return
+}
 func (ra *RmaxAgent) AgentCleanup() {
 }
 func (ra *RmaxAgent) AgentMessage(message string) string {
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"fmt"
+	"os"
 	"strings"
 	"strconv"
 	"rand"
 	"gonicetrace.googlecode.com/hg/nicetrace"
 	"goargcfg.googlecode.com/hg/argcfg"
-	"gostat.googlecode.com/hg/stat"
 	"go-glue.googlecode.com/hg/rlglue"
 	"github.com/skelterjohn/rlalg/rmax"
 	"go-glue.googlecode.com/hg/rltools/discrete"
 	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/fsss/fsssmdp"
 )
 
 type Config struct {
@@ -27,17 +29,24 @@ type RmaxFSSSAgent struct {
 	lastAction		discrete.Action
 	Cfg			Config
 	s			*fsss.Searcher
-	mdpo			*discrete.MDPOracle
+	mdpo			*fsssmdp.Oracle
+	//seeds each new searcher, and picks actions before there is one
+	rng			*rand.Rand
 	stepsWithPlanner	uint64
 }
 
 func NewRmaxFSSSAgent(cfg Config) (ra *RmaxFSSSAgent) {
 	ra = new(RmaxFSSSAgent)
 	ra.Cfg = cfg
+	ra.rng = rand.New(rand.NewSource(cfg.FS3.Seed))
 	return
 }
 func (ra *RmaxFSSSAgent) AgentInit(taskString string) {
 	ra.task, _ = rlglue.ParseTaskSpec(taskString)
+	//the search needs discounting, and the model's Vmax has to use the same gamma
+	if ra.task.DiscountFactor == 1 {
+		ra.task.DiscountFactor = 0.9
+	}
 	ra.rmdp = rmax.NewRmaxMDP(ra.task, ra.Cfg.M)
 }
 func (ra *RmaxFSSSAgent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
@@ -73,13 +82,16 @@ func (ra *RmaxFSSSAgent) AgentMessage(message string) string {
 	tokens := strings.Split(message, " ", -1)
 	if tokens[0] == "seed" {
 		seed, _ := strconv.Atoi64(tokens[1])
-		rand.Seed(seed)
+		ra.rng = rand.New(rand.NewSource(seed))
+		if ra.s != nil {
+			ra.s.Seed(ra.rng.Int63())
+		}
 	}
 	return ""
 }
 func (ra *RmaxFSSSAgent) GetAction() (action discrete.Action) {
 	if ra.s == nil {
-		action = discrete.Action(stat.NextRange(int64(ra.task.Act.Ints.Count())))
+		action = discrete.Action(ra.rng.Int63n(int64(ra.task.Act.Ints.Count())))
 		return
 	}
 	node := ra.s.GetNode(ra.stepsWithPlanner, ra.mdpo)
@@ -88,16 +100,15 @@ func (ra *RmaxFSSSAgent) GetAction() (action discrete.Action) {
 	return
 }
 func (ra *RmaxFSSSAgent) Forget() {
-	ra.mdpo = discrete.NewMDPOracle(ra.rmdp, ra.lastState)
+	ra.mdpo = fsssmdp.NewOracle(ra.rmdp, ra.lastState)
 	ra.s = fsss.New()
 	ra.s.Cfg = ra.Cfg.FS3
+	ra.s.Seed(ra.rng.Int63())
 	ra.s.NumActions = ra.rmdp.NumActions()
-	ra.s.Gamma = ra.task.DiscountFactor
-	if ra.s.Gamma == 1 {
-		ra.s.Gamma = 0.9
-	}
+	ra.s.Gamma = ra.rmdp.Gamma
 	ra.s.Vmin = ra.task.Reward.Min / (1 - ra.s.Gamma)
-	ra.s.Vmax = 5
+	//unknown state-actions pay the most reward forever
+	ra.s.Vmax = ra.rmdp.Vmax
 	ra.stepsWithPlanner = 0
 }
 func (ra *RmaxFSSSAgent) Plan() {
@@ -109,6 +120,8 @@ func (ra *RmaxFSSSAgent) Plan() {
 	var expanded uint64
 	for i := 0; i < int(ra.Cfg.NumTrajectories); i++ {
 		expanded += ra.s.RunTrajectory(root, ra.Cfg.Depth)
+		//Plan would evict between trajectories, so do it here too
+		ra.s.Evict(root)
 		if ra.Cfg.Budget != 0 && expanded > ra.Cfg.Budget {
 			break
 		}
@@ -122,6 +135,10 @@ func main() {
 	cfg.NumTrajectories = 500
 	cfg.FS3 = fsss.ConfigDefault()
 	argcfg.LoadArgs(&cfg)
+	if err := cfg.FS3.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 	agent := NewRmaxFSSSAgent(cfg)
 	rlglue.LoadAgent(agent)
 }
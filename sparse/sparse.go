@@ -0,0 +1,111 @@
+package sparse
+
+import (
+	"math"
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/leaf"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//Planner is the sparse sampling of Kearns, Mansour and Ng: every action at every
+//node gets C samples, all the way down to the depth asked for, so the work is
+//(NumActions*C)^depth. It is the exhaustive version of what fsss searches
+//selectively: with fsss.Config.ZeroAtDepthThreshold and the same C, the two
+//estimate the same root values in expectation. They draw their samples from
+//different streams, so on a stochastic MDP a given run of each will differ.
+type Planner struct {
+	C          uint64
+	NumActions uint64
+	Gamma      float64
+	//if set, values the nodes at the depth limit by the middle of its bounds.
+	//Otherwise they are worth 0.
+	Leaf leaf.Evaluator
+	rng  *rand.Rand
+}
+
+func New(c, numActions uint64, gamma float64) (this *Planner) {
+	this = new(Planner)
+	this.C = c
+	this.NumActions = numActions
+	this.Gamma = gamma
+	this.Seed(1)
+	return
+}
+
+//Seed reseeds the generator given to oracles and leaf evaluators that take one.
+func (this *Planner) Seed(seed int64) {
+	this.rng = rand.New(plan.NewSource(seed))
+}
+
+type actionFilter interface {
+	ActionAvailable(action discrete.Action) bool
+}
+
+//the fsss.SourcedOracle interface
+type sourcedOracle interface {
+	NextFrom(action discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64)
+}
+
+func (this *Planner) next(o discrete.Oracle, a discrete.Action) (discrete.Oracle, float64) {
+	if so, ok := o.(sourcedOracle); ok {
+		return so.NextFrom(a, this.rng)
+	}
+	return o.Next(a)
+}
+
+//Qs estimates each action's value at o with a tree depth levels deep, and says
+//how many samples it drew. Unavailable actions are -Inf. C and depth must be at
+//least 1.
+func (this *Planner) Qs(o discrete.Oracle, depth uint64) (qs []float64, expanded uint64) {
+	if this.C == 0 {
+		panic("sparse: C must be at least 1")
+	}
+	if depth == 0 {
+		panic("sparse: Qs needs a depth of at least 1")
+	}
+	depth--
+	qs = make([]float64, this.NumActions)
+	af, haveActionFilter := o.(actionFilter)
+	for a := range qs {
+		if haveActionFilter && !af.ActionAvailable(discrete.Action(a)) {
+			qs[a] = math.Inf(-1)
+			continue
+		}
+		for i := uint64(0); i < this.C; i++ {
+			no, r := this.next(o, discrete.Action(a))
+			v, e := this.V(no, depth)
+			qs[a] += r + this.Gamma*v
+			expanded += e + 1
+		}
+		qs[a] /= float64(this.C)
+	}
+	return
+}
+
+//V is the best of Qs, 0 for a terminal, and the leaf value once depth runs out.
+func (this *Planner) V(o discrete.Oracle, depth uint64) (v float64, expanded uint64) {
+	if o.Terminal() {
+		return
+	}
+	if depth == 0 {
+		if this.Leaf != nil {
+			lower, upper := leaf.EvaluateFrom(this.Leaf, o, this.rng)
+			v = (lower + upper) / 2
+		}
+		return
+	}
+	qs, expanded := this.Qs(o, depth)
+	v = qs[Best(qs)]
+	return
+}
+
+//Best is the first action with the highest Q.
+func Best(qs []float64) (a int) {
+	for i, q := range qs {
+		if q > qs[a] {
+			a = i
+		}
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package sparse
+
+import (
+	"math"
+	"testing"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//three states in a ring: action a moves a+1 steps on, and landing on state 0
+//pays 1. It is deterministic, so the two planners' different random streams
+//can't make them disagree.
+type ring uint64
+
+func (this ring) Hashcode() uint64 {
+	return uint64(this)
+}
+func (this ring) LessThan(other interface{}) bool {
+	return this < other.(ring)
+}
+func (this ring) Terminal() bool {
+	return false
+}
+func (this ring) Next(a discrete.Action) (o discrete.Oracle, r float64) {
+	next := (this + ring(a.Hashcode()) + 1) % 3
+	if next == 0 {
+		r = 1
+	}
+	o = next
+	return
+}
+
+func TestQsMatchesFSSS(t *testing.T) {
+	const (
+		c          = 2
+		numActions = 2
+		gamma      = 0.9
+		depth      = 4
+	)
+	p := New(c, numActions, gamma)
+	qs, sampled := p.Qs(ring(1), depth)
+	v := qs[Best(qs)]
+
+	s := fsss.New()
+	s.Cfg = fsss.ConfigDefault()
+	s.Cfg.C = c
+	s.Cfg.ZeroAtDepthThreshold = true
+	s.NumActions = numActions
+	s.Gamma = gamma
+	s.Vmin, s.Vmax = 0, 1/(1-gamma)
+	root := s.GetNode(0, ring(1))
+	s.SetRoot(root)
+	stats := s.Plan(root, depth, plan.Limits{Trajectories: 10000, Epsilon: 1e-9})
+	if stats.Stop != plan.StopGap {
+		t.Fatalf("fsss stopped for %v before its bounds met", stats.Stop)
+	}
+	vupper, vlower := root.GetValue()
+	if math.Fabs(vupper-v) > 1e-9 || math.Fabs(vlower-v) > 1e-9 {
+		t.Errorf("fsss bounds [%v, %v], sparse sampling %v", vlower, vupper, v)
+	}
+	//both count the samples they drew
+	if stats.Expanded > sampled {
+		t.Errorf("fsss drew %d samples, more than sparse sampling's %d", stats.Expanded, sampled)
+	}
+}
+
+func TestQsNeedsDepth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Qs at depth 0 did not panic")
+		}
+	}()
+	New(1, 2, 0.9).Qs(ring(0), 0)
+}
@@ -0,0 +1,165 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"json"
+	"os"
+	"sync"
+)
+
+type Kind int
+
+const (
+	TrajectoryStarted Kind = iota
+	TrajectoryFinished
+	NodeExpanded
+	Backup
+	ActionChosen
+	PlannerReset
+	PlanningFinished
+)
+
+var kindNames = []string{
+	"trajectory-started",
+	"trajectory-finished",
+	"node-expanded",
+	"backup",
+	"action-chosen",
+	"planner-reset",
+	"planning-finished",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind-%d", int(k))
+}
+
+//An Event is something a planner did. Only the fields that make sense for the
+//Kind are filled in.
+type Event struct {
+	Kind Kind
+	//depth of the node, for trajectory, node-expanded and backup events
+	Depth uint64
+	//nodes expanded, by one trajectory for TrajectoryFinished or by all of
+	//them for PlanningFinished
+	Expanded uint64
+	//trajectories run and wall-clock nanoseconds spent, for PlanningFinished
+	Trajectories uint64
+	Elapsed      int64
+	//the action chosen, or the best action after a backup
+	Action uint64
+	//the node's value bounds after a backup
+	Vlower, Vupper float64
+	//the Q values at the root, for ActionChosen
+	Q []float64
+	//the agent's step within the episode, for ActionChosen and PlannerReset
+	Step uint64
+	//why planning stopped, for PlanningFinished
+	Reason string
+}
+
+type Sink interface {
+	Emit(e Event)
+}
+
+//Nop drops every event.
+type Nop struct{}
+
+func (Nop) Emit(e Event) {}
+
+//Text writes one human-readable line per event.
+type Text struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewText(w io.Writer) (this *Text) {
+	this = new(Text)
+	this.w = w
+	return
+}
+func (this *Text) Emit(e Event) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	switch e.Kind {
+	case TrajectoryStarted, NodeExpanded:
+		fmt.Fprintf(this.w, "%v depth=%d\n", e.Kind, e.Depth)
+	case TrajectoryFinished:
+		fmt.Fprintf(this.w, "%v depth=%d expanded=%d\n", e.Kind, e.Depth, e.Expanded)
+	case Backup:
+		fmt.Fprintf(this.w, "%v depth=%d action=%d v=[%f,%f]\n", e.Kind, e.Depth, e.Action, e.Vlower, e.Vupper)
+	case ActionChosen:
+		fmt.Fprintf(this.w, "%v step=%d action=%d q=%v\n", e.Kind, e.Step, e.Action, e.Q)
+	case PlannerReset:
+		fmt.Fprintf(this.w, "%v step=%d\n", e.Kind, e.Step)
+	case PlanningFinished:
+		fmt.Fprintf(this.w, "%v depth=%d trajectories=%d expanded=%d elapsed=%dns reason=%s\n", e.Kind, e.Depth, e.Trajectories, e.Expanded, e.Elapsed, e.Reason)
+	default:
+		fmt.Fprintf(this.w, "%v\n", e.Kind)
+	}
+}
+
+//JSON writes one JSON object per line, with a "kind" field naming the event.
+type JSON struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewJSON(w io.Writer) (this *JSON) {
+	this = new(JSON)
+	this.w = w
+	return
+}
+func (this *JSON) Emit(e Event) {
+	m := map[string]interface{}{"kind": e.Kind.String()}
+	switch e.Kind {
+	case TrajectoryStarted, NodeExpanded:
+		m["depth"] = e.Depth
+	case TrajectoryFinished:
+		m["depth"] = e.Depth
+		m["expanded"] = e.Expanded
+	case Backup:
+		m["depth"] = e.Depth
+		m["action"] = e.Action
+		m["vlower"] = e.Vlower
+		m["vupper"] = e.Vupper
+	case ActionChosen:
+		m["step"] = e.Step
+		m["action"] = e.Action
+		m["q"] = e.Q
+	case PlannerReset:
+		m["step"] = e.Step
+	case PlanningFinished:
+		m["depth"] = e.Depth
+		m["trajectories"] = e.Trajectories
+		m["expanded"] = e.Expanded
+		m["elapsed"] = e.Elapsed
+		m["reason"] = e.Reason
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{"kind": e.Kind.String(), "error": err.String()})
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.w.Write(b)
+	this.w.Write([]byte{'\n'})
+}
+
+//New picks a sink by name: "" or "none", "text" or "json".
+func New(name string, w io.Writer) (sink Sink, err os.Error) {
+	switch name {
+	case "", "none":
+		sink = Nop{}
+	case "text":
+		sink = NewText(w)
+	case "json":
+		sink = NewJSON(w)
+	default:
+		err = fmt.Errorf("telemetry: unknown sink %q", name)
+	}
+	return
+}
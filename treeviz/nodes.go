@@ -0,0 +1,93 @@
+package treeviz
+
+import (
+	"gohash.googlecode.com/hg/hashlessmap"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/uct"
+)
+
+type fsssNode struct {
+	n *fsss.Node
+}
+
+//FSSS exports an fsss tree.
+func FSSS(n *fsss.Node) Node {
+	return fsssNode{n}
+}
+
+func (this fsssNode) NumActions() int {
+	return this.n.GetNumActions()
+}
+func (this fsssNode) Branch(a int) (children []Node, counts []float64) {
+	var oracles []hashlessmap.HasherLess
+	for nn, count := range this.n.GetBranch(a) {
+		oracles = append(oracles, nn.GetOracle())
+		children = append(children, fsssNode{nn})
+		counts = append(counts, count)
+	}
+	sortBranch(oracles, children, counts)
+	return
+}
+func (this fsssNode) R(a int) float64 {
+	return this.n.GetR(a)
+}
+func (this fsssNode) Q(a int) (lower, upper float64) {
+	upper, lower = this.n.GetQ(a)
+	return
+}
+func (this fsssNode) Value() (lower, upper float64) {
+	upper, lower = this.n.GetValue()
+	return
+}
+func (this fsssNode) Visits() uint64 {
+	return this.n.GetVisits()
+}
+func (this fsssNode) Best() int {
+	return int(this.n.GetBestAction())
+}
+func (this fsssNode) Key() interface{} {
+	return this.n
+}
+
+type uctNode struct {
+	s *uct.Searcher
+	n *uct.Node
+}
+
+//UCT exports a uct tree. Its Q bounds are both the backed-up discounted Q, and
+//its value bounds both V.
+func UCT(s *uct.Searcher, n *uct.Node) Node {
+	return uctNode{s, n}
+}
+
+func (this uctNode) NumActions() int {
+	return len(this.n.Branches)
+}
+func (this uctNode) Branch(a int) (children []Node, counts []float64) {
+	var oracles []hashlessmap.HasherLess
+	for nn, count := range this.n.Branches[a] {
+		oracles = append(oracles, nn.GetOracle())
+		children = append(children, uctNode{this.s, nn})
+		counts = append(counts, count)
+	}
+	sortBranch(oracles, children, counts)
+	return
+}
+func (this uctNode) R(a int) float64 {
+	return this.n.R[a]
+}
+func (this uctNode) Q(a int) (lower, upper float64) {
+	return this.n.Q[a], this.n.Q[a]
+}
+func (this uctNode) Value() (lower, upper float64) {
+	return this.n.V, this.n.V
+}
+func (this uctNode) Visits() uint64 {
+	return uint64(this.n.TotalVisits)
+}
+func (this uctNode) Best() int {
+	return int(this.s.GetAction(this.n))
+}
+func (this uctNode) Key() interface{} {
+	return this.n
+}
@@ -0,0 +1,193 @@
+package treeviz
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"json"
+	"os"
+	"sort"
+	"gohash.googlecode.com/hg/hashlessmap"
+)
+
+//A Node is a search tree node as the exporters see it. FSSS and UCT wrap the
+//nodes of those searchers.
+type Node interface {
+	NumActions() int
+	//the next nodes sampled after a, with how many times each came up, in the
+	//order of their oracles
+	Branch(a int) (children []Node, counts []float64)
+	R(a int) float64
+	Q(a int) (lower, upper float64)
+	Value() (lower, upper float64)
+	Visits() uint64
+	//the action the searcher would choose here
+	Best() int
+	//identifies the node, so that one reached along several paths is written once
+	Key() interface{}
+}
+
+type Options struct {
+	//nodes deeper than this are written without their branches. 0 is no limit.
+	MaxDepth uint64
+	//branches sampled fewer times than this are left out
+	MinCount float64
+}
+
+type edge struct {
+	child int
+	count float64
+}
+
+type visit struct {
+	node  Node
+	depth uint64
+	//nil for nodes past MaxDepth
+	edges [][]edge
+}
+
+//walk numbers the nodes reachable from root, breadth first.
+func walk(root Node, opt Options) (visits []*visit) {
+	ids := map[interface{}]int{root.Key(): 0}
+	visits = []*visit{&visit{node: root}}
+	for i := 0; i < len(visits); i++ {
+		v := visits[i]
+		if opt.MaxDepth != 0 && v.depth >= opt.MaxDepth {
+			continue
+		}
+		v.edges = make([][]edge, v.node.NumActions())
+		for a := range v.edges {
+			children, counts := v.node.Branch(a)
+			for j, child := range children {
+				if counts[j] < opt.MinCount {
+					continue
+				}
+				id, ok := ids[child.Key()]
+				if !ok {
+					id = len(visits)
+					ids[child.Key()] = id
+					visits = append(visits, &visit{node: child, depth: v.depth + 1})
+				}
+				v.edges[a] = append(v.edges[a], edge{id, counts[j]})
+			}
+		}
+	}
+	return
+}
+
+func sampled(edges [][]edge) bool {
+	for _, e := range edges {
+		if len(e) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//WriteDOT writes the tree below root as a Graphviz digraph. Each node shows its
+//value bounds and visits, and each of its actions is a point joined to it by an
+//edge with the action's reward and Q bounds, and to the next nodes by edges
+//with their counts. The chosen action's edge is red.
+func WriteDOT(w io.Writer, root Node, opt Options) (err os.Error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph tree {\n")
+	for id, v := range walk(root, opt) {
+		lower, upper := v.node.Value()
+		fmt.Fprintf(&buf, "\tn%d [label=\"v=[%.3f,%.3f]\\nvisits=%d\"];\n", id, lower, upper, v.node.Visits())
+		best := v.node.Best()
+		if !sampled(v.edges) {
+			//a leaf, or every branch was left out
+			best = -1
+		}
+		for a, edges := range v.edges {
+			if len(edges) == 0 && a != best {
+				continue
+			}
+			qlower, qupper := v.node.Q(a)
+			style := ""
+			if a == best {
+				style = ", color=red, penwidth=2"
+			}
+			fmt.Fprintf(&buf, "\tn%d_a%d [shape=point];\n", id, a)
+			fmt.Fprintf(&buf, "\tn%d -> n%d_a%d [label=\"a=%d r=%.3f\\nq=[%.3f,%.3f]\"%s];\n", id, id, a, a, v.node.R(a), qlower, qupper, style)
+			for _, e := range edges {
+				fmt.Fprintf(&buf, "\tn%d_a%d -> n%d [label=\"%g\"];\n", id, a, e.child, e.count)
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "}\n")
+	_, err = w.Write(buf.Bytes())
+	return
+}
+
+//WriteJSON writes the tree below root as {"nodes": [...]}, where each node has
+//an "id", "depth", "visits", "vlower", "vupper", "best" and, unless it is past
+//MaxDepth, "actions". Each action has "action", "r", "qlower", "qupper" and
+//"children", a list of {"node": id, "count": c}. The root's id is 0.
+func WriteJSON(w io.Writer, root Node, opt Options) (err os.Error) {
+	var nodes []interface{}
+	for id, v := range walk(root, opt) {
+		lower, upper := v.node.Value()
+		m := map[string]interface{}{
+			"id":     id,
+			"depth":  v.depth,
+			"visits": v.node.Visits(),
+			"vlower": lower,
+			"vupper": upper,
+			"best":   v.node.Best(),
+		}
+		if v.edges != nil {
+			var actions []interface{}
+			for a, edges := range v.edges {
+				qlower, qupper := v.node.Q(a)
+				var children []interface{}
+				for _, e := range edges {
+					children = append(children, map[string]interface{}{"node": e.child, "count": e.count})
+				}
+				actions = append(actions, map[string]interface{}{
+					"action":   a,
+					"r":        v.node.R(a),
+					"qlower":   qlower,
+					"qupper":   qupper,
+					"children": children,
+				})
+			}
+			m["actions"] = actions
+		}
+		nodes = append(nodes, m)
+	}
+	b, err := json.Marshal(map[string]interface{}{"nodes": nodes})
+	if err != nil {
+		return
+	}
+	_, err = w.Write(append(b, '\n'))
+	return
+}
+
+//orders a branch's children by their oracles, so that the output doesn't
+//depend on the order the searcher's maps give them in
+type byOracle struct {
+	oracles  []hashlessmap.HasherLess
+	children []Node
+	counts   []float64
+}
+
+func (this byOracle) Len() int {
+	return len(this.children)
+}
+func (this byOracle) Less(i, j int) bool {
+	hi, hj := this.oracles[i].Hashcode(), this.oracles[j].Hashcode()
+	if hi != hj {
+		return hi < hj
+	}
+	return this.oracles[i].LessThan(this.oracles[j])
+}
+func (this byOracle) Swap(i, j int) {
+	this.oracles[i], this.oracles[j] = this.oracles[j], this.oracles[i]
+	this.children[i], this.children[j] = this.children[j], this.children[i]
+	this.counts[i], this.counts[j] = this.counts[j], this.counts[i]
+}
+
+func sortBranch(oracles []hashlessmap.HasherLess, children []Node, counts []float64) {
+	sort.Sort(byOracle{oracles, children, counts})
+}
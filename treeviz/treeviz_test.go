@@ -0,0 +1,112 @@
+package treeviz
+
+import (
+	"bytes"
+	"json"
+	"rand"
+	"reflect"
+	"testing"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss"
+	"github.com/skelterjohn/rlalg/plan"
+)
+
+//three states: action a pays a and moves to a state drawn from the generator
+type three uint64
+
+func (this three) Hashcode() uint64 {
+	return uint64(this)
+}
+func (this three) LessThan(other interface{}) bool {
+	return this < other.(three)
+}
+func (this three) Terminal() bool {
+	return false
+}
+func (this three) Next(a discrete.Action) (o discrete.Oracle, r float64) {
+	panic("three draws from the searcher's generator")
+}
+func (this three) NextFrom(a discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64) {
+	return three(rng.Intn(3)), float64(a.Hashcode())
+}
+
+//a seeded search, so that the tree is the same every run
+func fixedTree() *fsss.Node {
+	s := fsss.New()
+	s.Cfg = fsss.ConfigDefault()
+	s.Cfg.C = 3
+	s.NumActions = 2
+	s.Gamma = 0.5
+	s.Vmin, s.Vmax = 0, 2
+	s.Seed(1)
+	root := s.GetNode(0, three(0))
+	s.Plan(root, 2, plan.Limits{Trajectories: 2})
+	return root
+}
+
+const goldenDOT = `digraph tree {
+	n0 [label="v=[1.500,2.000]\nvisits=2"];
+	n0_a0 [shape=point];
+	n0 -> n0_a0 [label="a=0 r=0.000\nq=[0.167,1.000]"];
+	n0_a0 -> n1 [label="1"];
+	n0_a0 -> n2 [label="1"];
+	n0_a0 -> n3 [label="1"];
+	n0_a1 [shape=point];
+	n0 -> n0_a1 [label="a=1 r=1.000\nq=[1.500,2.000]", color=red, penwidth=2];
+	n0_a1 -> n1 [label="3"];
+	n1 [label="v=[1.000,2.000]\nvisits=2"];
+	n1_a0 [shape=point];
+	n1 -> n1_a0 [label="a=0 r=0.000\nq=[0.000,1.000]"];
+	n1_a0 -> n4 [label="2"];
+	n1_a0 -> n5 [label="1"];
+	n1_a1 [shape=point];
+	n1 -> n1_a1 [label="a=1 r=1.000\nq=[1.000,2.000]", color=red, penwidth=2];
+	n1_a1 -> n6 [label="1"];
+	n1_a1 -> n4 [label="1"];
+	n1_a1 -> n5 [label="1"];
+	n2 [label="v=[0.000,2.000]\nvisits=0"];
+	n3 [label="v=[0.000,2.000]\nvisits=0"];
+	n4 [label="v=[0.000,2.000]\nvisits=0"];
+	n5 [label="v=[0.000,2.000]\nvisits=0"];
+	n6 [label="v=[0.000,2.000]\nvisits=0"];
+}
+`
+
+const goldenJSON = `{"nodes": [
+	{"id": 0, "depth": 0, "visits": 2, "vlower": 1.5, "vupper": 2, "best": 1, "actions": [
+		{"action": 0, "r": 0, "qlower": 0.16666666666666666, "qupper": 1, "children": [
+			{"node": 1, "count": 1}, {"node": 2, "count": 1}, {"node": 3, "count": 1}]},
+		{"action": 1, "r": 1, "qlower": 1.5, "qupper": 2, "children": [
+			{"node": 1, "count": 3}]}]},
+	{"id": 1, "depth": 1, "visits": 2, "vlower": 1, "vupper": 2, "best": 1},
+	{"id": 2, "depth": 1, "visits": 0, "vlower": 0, "vupper": 2, "best": 0},
+	{"id": 3, "depth": 1, "visits": 0, "vlower": 0, "vupper": 2, "best": 0}]}
+`
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, FSSS(fixedTree()), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != goldenDOT {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), goldenDOT)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, FSSS(fixedTree()), Options{MaxDepth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	//compared as values, since the order of an object's keys is up to the encoder
+	var got, want interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(goldenJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), goldenJSON)
+	}
+}
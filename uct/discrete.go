@@ -0,0 +1,60 @@
+package uct
+
+import (
+	"rand"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/leaf"
+)
+
+//DiscreteOracle lets a discrete.Oracle, like a discrete.MDPOracle, be searched.
+type DiscreteOracle struct {
+	discrete.Oracle
+}
+
+type discreteActionFilter interface {
+	ActionAvailable(action discrete.Action) bool
+}
+
+//the fsss.SourcedOracle interface, without importing fsss
+type discreteSourcedOracle interface {
+	NextFrom(action discrete.Action, rng *rand.Rand) (o discrete.Oracle, r float64)
+}
+
+func (this DiscreteOracle) Next(action uint64) (o Oracle, r float64) {
+	no, r := this.Oracle.Next(discrete.Action(action))
+	o = DiscreteOracle{no}
+	return
+}
+//NextFrom draws from rng if the wrapped oracle takes a generator.
+func (this DiscreteOracle) NextFrom(action uint64, rng *rand.Rand) (o Oracle, r float64) {
+	so, ok := this.Oracle.(discreteSourcedOracle)
+	if !ok {
+		return this.Next(action)
+	}
+	no, r := so.NextFrom(discrete.Action(action), rng)
+	o = DiscreteOracle{no}
+	return
+}
+func (this DiscreteOracle) LessThan(other interface{}) bool {
+	return this.Oracle.LessThan(other.(DiscreteOracle).Oracle)
+}
+func (this DiscreteOracle) ActionAvailable(action uint64) bool {
+	af, ok := this.Oracle.(discreteActionFilter)
+	return !ok || af.ActionAvailable(discrete.Action(action))
+}
+
+//DiscreteLeaf uses a leaf.Evaluator on DiscreteOracles, valuing a leaf at the
+//middle of its bounds.
+type DiscreteLeaf struct {
+	leaf.Evaluator
+}
+
+func (this DiscreteLeaf) Evaluate(o Oracle) (v float64) {
+	return this.EvaluateFrom(o, nil)
+}
+
+//EvaluateFrom passes rng on to the evaluator if it takes a generator.
+func (this DiscreteLeaf) EvaluateFrom(o Oracle, rng *rand.Rand) (v float64) {
+	lower, upper := leaf.EvaluateFrom(this.Evaluator, o.(DiscreteOracle).Oracle, rng)
+	return (lower + upper) / 2
+}
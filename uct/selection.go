@@ -0,0 +1,89 @@
+package uct
+
+import (
+	"math"
+)
+
+//A SelectionRule scores the actions at a node. The trajectory takes the
+//available action with the highest score.
+type SelectionRule interface {
+	Score(n *Node, a uint64) float64
+}
+
+//UCB1 is Q plus Beta times the UCB1 bonus. Untried actions come first, even
+//with Beta 0.
+type UCB1 struct{}
+
+func (UCB1) Score(n *Node, a uint64) float64 {
+	if n.Visits[a] == 0 {
+		return math.Inf(1)
+	}
+	return n.Q[a] + n.s.Cfg.Beta*UCBBonus(n.Visits[a], n.TotalVisits)
+}
+
+//UCB1Tuned scales the UCB1 bonus by the variance of the returns seen after
+//the action, capped at 1/4, as in Auer, Cesa-Bianchi and Fischer. Their bound is
+//for returns in [0,1], so returns are measured in units of Config.ValueRange.
+type UCB1Tuned struct{}
+
+func (UCB1Tuned) Score(n *Node, a uint64) float64 {
+	if n.Visits[a] == 0 {
+		return math.Inf(1)
+	}
+	width := n.s.Cfg.ValueRange
+	if width == 0 {
+		width = 1
+	}
+	visits := float64(n.Visits[a])
+	logTotal := math.Log(float64(n.TotalVisits))
+	variance := (n.SumReturn2[a]/visits - n.MeanReturn[a]*n.MeanReturn[a]) / (width * width)
+	variance += math.Sqrt(2 * logTotal / visits)
+	if variance > 0.25 {
+		variance = 0.25
+	}
+	return n.Q[a] + n.s.Cfg.Beta*width*math.Sqrt(logTotal/visits*variance)
+}
+
+//PUCT weighs the exploration term by the action's probability under
+//Config.Prior, so untried actions need not all be tried first.
+type PUCT struct{}
+
+func (PUCT) Score(n *Node, a uint64) float64 {
+	if n.prior == nil {
+		if n.s.Cfg.Prior == nil {
+			panic("uct: puct needs Config.Prior")
+		}
+		n.prior = n.s.Cfg.Prior(n.o)
+	}
+	return n.Q[a] + n.s.Cfg.Beta*n.prior[a]*math.Sqrt(float64(n.TotalVisits))/float64(1+n.Visits[a])
+}
+
+//RAVE mixes Q with the all-moves-as-first value, trusting the latter less as
+//the node's visits grow past Config.RaveK, and adds the UCB1 bonus.
+type RAVE struct{}
+
+func (RAVE) Score(n *Node, a uint64) float64 {
+	if n.Visits[a] == 0 {
+		if n.AMAFVisits[a] == 0 {
+			return math.Inf(1)
+		}
+		return n.AMAFQ[a] + n.s.Cfg.Beta*UCBBonus(n.AMAFVisits[a], n.TotalVisits)
+	}
+	k := n.s.Cfg.RaveK
+	beta := math.Sqrt(k / (3*float64(n.TotalVisits) + k))
+	q := (1-beta)*n.Q[a] + beta*n.AMAFQ[a]
+	return q + n.s.Cfg.Beta*UCBBonus(n.Visits[a], n.TotalVisits)
+}
+
+//every action taken from here on in the trajectory counts as if it were taken here
+func (n *Node) updateAMAF(actions []uint64, ret float64) {
+	seen := make(map[uint64]bool)
+	for _, a := range actions {
+		if seen[a] {
+			continue
+		}
+		seen[a] = true
+		n.AMAFVisits[a]++
+		n.AMAFQ[a] += (ret - n.AMAFQ[a]) / float64(n.AMAFVisits[a])
+	}
+}
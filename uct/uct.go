@@ -1,17 +1,59 @@
 package uct
 
 import (
+	"fmt"
 	"math"
+	"os"
+	"rand"
 	"gohash.googlecode.com/hg/hashlessmap"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/telemetry"
 )
 
 type Config struct {
-	Beta  float64
+	Beta float64
+	//agents use the task's discount factor if this is 0
 	Gamma float64
+	//how many random steps a rollout from a new leaf takes
+	Horizon uint64
+	Memoize bool
+	//if set, values new leaves instead of the random rollout
+	Leaf LeafEvaluator
+	//the selection rule, one of "ucb1", "ucb1-tuned", "puct" or "rave"
+	Rule string
+	//if set, used instead of Rule
+	Selection SelectionRule
+	//action probabilities for "puct"
+	Prior func(o Oracle) []float64
+	//how many visits it takes for "rave" to weigh its own and the AMAF values equally
+	RaveK float64
+	//progressive widening: if WideningK is not 0, an action tried n times has
+	//at most WideningK*n^WideningAlpha children
+	WideningK     float64
+	WideningAlpha float64
+	//the width of the range returns fall in, which "ucb1-tuned" measures
+	//variance against. Agents take it from the task if it is 0; otherwise 0 is 1.
+	ValueRange float64
+	//seeds the Searcher's random source, unless Seed or SetSource is called
+	Seed int64
+}
+
+//A LeafEvaluator gives the value of a new leaf. DiscreteLeaf adapts the
+//evaluators in package leaf.
+type LeafEvaluator interface {
+	Evaluate(o Oracle) (v float64)
 }
 
 func ConfigDefault() (cfg Config) {
 	cfg.Beta = 1
+	cfg.Horizon = 10
+	cfg.Memoize = true
+	cfg.Rule = "ucb1"
+	cfg.RaveK = 100
+	cfg.WideningK = 0
+	cfg.WideningAlpha = 0.5
+	cfg.ValueRange = 0
+	cfg.Seed = 1
 	return
 }
 
@@ -27,40 +69,383 @@ type ActionFilter interface {
 	ActionAvailable(action uint64) bool
 }
 
+//A SourcedLeafEvaluator can draw whatever randomness it needs from the
+//Searcher's generator. DiscreteLeaf is one.
+type SourcedLeafEvaluator interface {
+	EvaluateFrom(o Oracle, rng *rand.Rand) (v float64)
+}
+
+//A SourcedOracle can draw its next states from the Searcher's generator, so that
+//a seeded search is reproducible.
+type SourcedOracle interface {
+	NextFrom(action uint64, rng *rand.Rand) (o Oracle, r float64)
+}
+
+type Searcher struct {
+	Cfg        Config
+	NumActions uint64
+	nodes      *hashlessmap.Map
+	Telemetry  telemetry.Sink
+	rng        *rand.Rand
+}
+
+func New() (s *Searcher) {
+	s = &Searcher{}
+	s.nodes = hashlessmap.New()
+	s.Telemetry = telemetry.Nop{}
+	return
+}
+
 type Node struct {
-	cfg *Config
+	s *Searcher
+	o Oracle
+	//has this Node had its rollout yet?
+	leaf     bool
+	terminal bool
 
 	R           []float64
 	Q           []float64
 	QBonus      []float64
 	V           float64
 	Branches    []map[*Node]float64
+	//the children of each action in the order they were first sampled, which
+	//is the order they are backed up in, so sums come out the same every run
+	order       [][]*Node
 	Visits      []int
 	TotalVisits int
+
+	//mean and sum of squares of the returns seen after each action
+	MeanReturn []float64
+	SumReturn2 []float64
+	//all-moves-as-first statistics, kept for "rave"
+	AMAFQ      []float64
+	AMAFVisits []int
+	//the action prior for "puct", fetched on first use
+	prior []float64
+}
+
+func newNode(s *Searcher, o Oracle) (this *Node) {
+	this = &Node{}
+	this.s = s
+	this.o = o
+	this.leaf = true
+	this.terminal = o.Terminal()
+	this.R = make([]float64, s.NumActions)
+	this.Q = make([]float64, s.NumActions)
+	this.QBonus = make([]float64, s.NumActions)
+	this.Branches = make([]map[*Node]float64, s.NumActions)
+	this.order = make([][]*Node, s.NumActions)
+	for a := range this.Branches {
+		this.Branches[a] = make(map[*Node]float64)
+	}
+	this.Visits = make([]int, s.NumActions)
+	this.MeanReturn = make([]float64, s.NumActions)
+	this.SumReturn2 = make([]float64, s.NumActions)
+	this.AMAFQ = make([]float64, s.NumActions)
+	this.AMAFVisits = make([]int, s.NumActions)
+	return
 }
 
-func GetNode(o Oracle) (this *Node) {
+//GetNode finds the Node for o, making it if needed.
+func (s *Searcher) GetNode(o Oracle) (this *Node) {
+	if o == nil {
+		panic("nil oracle")
+	}
+	if !s.Cfg.Memoize {
+		return newNode(s, o)
+	}
+	if ri, ok := s.nodes.Get(o); ok {
+		return ri.(*Node)
+	}
+	this = newNode(s, o)
+	s.nodes.Put(o, this)
 	return
 }
 
+//Forget drops every memoized Node.
+func (s *Searcher) Forget() {
+	s.nodes = hashlessmap.New()
+}
+
+func (this *Node) GetOracle() Oracle {
+	return this.o
+}
+
+func (this *Node) available(a uint64) bool {
+	af, ok := this.o.(ActionFilter)
+	return !ok || af.ActionAvailable(a)
+}
+
+//the UCB1 exploration term for an action tried visits times out of totalVisits
 func UCBBonus(visits, totalVisits int) (bonus float64) {
-	bonus = math.Sqrt(math.Log(float64(visits)) / float64(totalVisits))
+	if visits == 0 {
+		return math.Inf(1)
+	}
+	bonus = math.Sqrt(math.Log(float64(totalVisits)) / float64(visits))
 	return
 }
 
+//the SelectionRule cfg asks for
+func (cfg Config) rule() (rule SelectionRule, err os.Error) {
+	if cfg.Selection != nil {
+		return cfg.Selection, nil
+	}
+	switch cfg.Rule {
+	case "", "ucb1":
+		rule = UCB1{}
+	case "ucb1-tuned":
+		rule = UCB1Tuned{}
+	case "puct":
+		if cfg.Prior == nil {
+			err = fmt.Errorf("uct: puct needs Config.Prior")
+		}
+		rule = PUCT{}
+	case "rave":
+		rule = RAVE{}
+	default:
+		err = fmt.Errorf("uct: unknown selection rule %q", cfg.Rule)
+	}
+	return
+}
+
+//Check reports settings a search cannot run with. Agents check their Config
+//when they are made, so that a bad one fails before any search does.
+func (cfg Config) Check() (err os.Error) {
+	_, err = cfg.rule()
+	return
+}
+
+//argmax over Q plus the exploration bonus, untried actions first
+func (s *Searcher) selection() SelectionRule {
+	rule, err := s.Cfg.rule()
+	if err != nil {
+		panic(err.String())
+	}
+	return rule
+}
+
+//the available action with the best score under the searcher's rule, and
+//whether there was any available action at all
+func (this *Node) selectAction() (action uint64, ok bool) {
+	rule := this.s.selection()
+	best := math.Inf(-1)
+	for a := uint64(0); a < this.s.NumActions; a++ {
+		if !this.available(a) {
+			continue
+		}
+		score := rule.Score(this, a)
+		if !ok || score > best {
+			best, action, ok = score, a, true
+		}
+	}
+	return
+}
+
+//Backup recomputes each tried action's Q from its average reward and the
+//values of the nodes it led to, and V as the best available Q.
 func (this *Node) Backup() {
-	this.V = math.Log(0)
+	if this.TotalVisits == 0 {
+		return
+	}
+	this.V = math.Inf(-1)
 
 	for a := range this.Q {
-		for child, count := range this.Branches[a] {
-			this.Q[a] += child.V * count
+		if this.Visits[a] == 0 {
+			continue
+		}
+		this.Q[a] = 0
+		for _, child := range this.order[a] {
+			this.Q[a] += child.V * this.Branches[a][child]
 		}
 		this.Q[a] /= float64(this.Visits[a])
-		this.Q[a] *= this.cfg.Gamma
+		this.Q[a] *= this.s.Cfg.Gamma
 		this.Q[a] += this.R[a]
-		if this.Q[a] > this.V {
+		if this.Q[a] > this.V && this.available(uint64(a)) {
 			this.V = this.Q[a]
 		}
-		this.QBonus[a] = this.cfg.Beta * UCBBonus(this.Visits[a], this.TotalVisits)
+		this.QBonus[a] = this.s.Cfg.Beta * UCBBonus(this.Visits[a], this.TotalVisits)
+	}
+}
+
+//the actions o allows
+func (s *Searcher) availableActions(o Oracle) (actions []uint64) {
+	af, haveActionFilter := o.(ActionFilter)
+	for a := uint64(0); a < s.NumActions; a++ {
+		if !haveActionFilter || af.ActionAvailable(a) {
+			actions = append(actions, a)
+		}
 	}
+	return
+}
+
+//discounted return of random actions from o for up to length steps, or until
+//no action is available
+func (s *Searcher) rollout(o Oracle, length uint64) (ret float64) {
+	discount := 1.0
+	for i := uint64(0); i < length && !o.Terminal(); i++ {
+		actions := s.availableActions(o)
+		if len(actions) == 0 {
+			break
+		}
+		a := actions[s.random().Intn(len(actions))]
+		var r float64
+		o, r = s.step(o, a)
+		ret += discount * r
+		discount *= s.Cfg.Gamma
+	}
+	return
+}
+
+//RunTrajectory walks down from n for at most length steps, choosing actions by
+//UCB, until it reaches a new leaf. That leaf is valued with a rollout, and the
+//nodes along the way are backed up.
+func (s *Searcher) RunTrajectory(n *Node, length uint64) (expanded uint64) {
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.TrajectoryStarted})
+	expanded, _ = s.runTrajectoryAux(n, length, 0, new([]uint64))
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.TrajectoryFinished, Expanded: expanded})
+	return
+}
+
+//next samples a new child for a, or, once progressive widening has given a
+//all the children it may have, revisits one in proportion to its count.
+func (n *Node) next(a uint64) (nn *Node, r float64) {
+	if !n.available(a) {
+		panic(fmt.Sprintf("uct: action %d is not available", a))
+	}
+	if k := n.s.Cfg.WideningK; k != 0 {
+		limit := math.Ceil(k * math.Pow(float64(n.Visits[a]+1), n.s.Cfg.WideningAlpha))
+		if float64(len(n.Branches[a])) >= limit && n.Visits[a] != 0 {
+			pick := n.s.random().Float64() * float64(n.Visits[a])
+			for _, child := range n.order[a] {
+				nn = child
+				if pick -= n.Branches[a][child]; pick < 0 {
+					break
+				}
+			}
+			return nn, n.R[a]
+		}
+	}
+	no, r := n.s.step(n.o, a)
+	nn = n.s.GetNode(no)
+	if n.Branches[a][nn] == 0 {
+		n.order[a] = append(n.order[a], nn)
+	}
+	return
+}
+
+//Seed makes the Searcher draw from a source seeded with seed.
+func (s *Searcher) Seed(seed int64) {
+	s.SetSource(plan.NewSource(seed))
+}
+
+//SetSource makes the Searcher draw its rollout actions, and the next states of
+//SourcedOracles, from src.
+func (s *Searcher) SetSource(src rand.Source) {
+	s.rng = rand.New(src)
+}
+
+func (s *Searcher) random() *rand.Rand {
+	if s.rng == nil {
+		s.Seed(s.Cfg.Seed)
+	}
+	return s.rng
+}
+
+//evaluate values a new leaf with Cfg.Leaf, from the Searcher's generator if it
+//takes one.
+func (s *Searcher) evaluate(o Oracle) (v float64) {
+	if se, ok := s.Cfg.Leaf.(SourcedLeafEvaluator); ok {
+		return se.EvaluateFrom(o, s.random())
+	}
+	return s.Cfg.Leaf.Evaluate(o)
+}
+
+//step samples o's next state from the Searcher's generator if o takes one.
+func (s *Searcher) step(o Oracle, a uint64) (no Oracle, r float64) {
+	if so, ok := o.(SourcedOracle); ok {
+		return so.NextFrom(a, s.random())
+	}
+	return o.Next(a)
+}
+
+//returns how many leaves were expanded, and the discounted return from n
+func (s *Searcher) runTrajectoryAux(n *Node, length, depth uint64, actions *[]uint64) (expanded uint64, ret float64) {
+	if n == nil {
+		panic("RunTrajectory(nil)")
+	}
+	if n.terminal {
+		n.V = 0
+		return
+	}
+	if n.leaf {
+		n.leaf = false
+		if s.Cfg.Leaf != nil {
+			n.V = s.evaluate(n.o)
+		} else {
+			n.V = s.rollout(n.o, s.Cfg.Horizon)
+		}
+		s.Telemetry.Emit(telemetry.Event{Kind: telemetry.NodeExpanded, Depth: depth})
+		return 1, n.V
+	}
+	if length == 0 {
+		return 0, n.V
+	}
+	a, ok := n.selectAction()
+	if !ok {
+		//a dead end keeps the value its leaf was given
+		return 0, n.V
+	}
+	nn, r := n.next(a)
+	n.Visits[a]++
+	n.TotalVisits++
+	n.R[a] += (r - n.R[a]) / float64(n.Visits[a])
+	n.Branches[a][nn]++
+	first := len(*actions)
+	*actions = append(*actions, a)
+	var tail float64
+	expanded, tail = s.runTrajectoryAux(nn, length-1, depth+1, actions)
+	ret = r + s.Cfg.Gamma*tail
+	n.MeanReturn[a] += (ret - n.MeanReturn[a]) / float64(n.Visits[a])
+	n.SumReturn2[a] += ret * ret
+	if _, ok := s.selection().(RAVE); ok {
+		n.updateAMAF((*actions)[first:], ret)
+	}
+	n.Backup()
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.Backup, Depth: depth, Action: s.GetAction(n), Vlower: n.V, Vupper: n.V})
+	return
+}
+
+//Plan runs trajectories of the given length from root until one of lim's limits
+//is reached. With no limits at all it runs nothing. It ignores Workers,
+//Separated and Epsilon.
+func (s *Searcher) Plan(root *Node, length uint64, lim plan.Limits) (stats plan.Stats) {
+	stats = plan.Run(lim, func() uint64 {
+		return s.RunTrajectory(root, length)
+	}, nil)
+	s.Telemetry.Emit(telemetry.Event{Kind: telemetry.PlanningFinished, Trajectories: stats.Trajectories, Expanded: stats.Expanded, Elapsed: stats.Elapsed, Reason: stats.Stop.String()})
+	return
+}
+
+//GetAction is the available action with the best Q among those tried, or the
+//first available action if none has been tried. If no action is available at
+//all it gives 0.
+func (s *Searcher) GetAction(n *Node) (action uint64) {
+	best := math.Inf(-1)
+	seen, tried := false, false
+	for a := uint64(0); a < s.NumActions; a++ {
+		if !n.available(a) {
+			continue
+		}
+		if !seen {
+			action, seen = a, true
+		}
+		if n.Visits[a] != 0 && (!tried || n.Q[a] > best) {
+			best, action, tried = n.Q[a], a, true
+		}
+	}
+	return
+}
+
+func (s *Searcher) GetQs(n *Node) []float64 {
+	return n.Q
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"gonicetrace.googlecode.com/hg/nicetrace"
+	"goargcfg.googlecode.com/hg/argcfg"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/uct/uctmdp"
+)
+
+type Config struct {
+	UCT		uctmdp.Config
+	//the MDP to plan in, as lines of "t s a n p" and "r s a r", over the
+	//task's state and action indices
+	MDP		string
+	Telemetry	string
+}
+
+type tableMDP struct {
+	discrete.FlatMDP
+}
+
+func (this *tableMDP) T(s discrete.State, a discrete.Action, n discrete.State) float64 {
+	return this.Transitions[s][a][n]
+}
+func (this *tableMDP) R(s discrete.State, a discrete.Action) float64 {
+	return this.Rewards[s][a]
+}
+
+func loadMDP(path string, task *rlglue.TaskSpec) (mdp *tableMDP, err os.Error) {
+	numStates := task.Obs.Ints.Count()
+	numActions := task.Act.Ints.Count()
+	mdp = new(tableMDP)
+	mdp.Task = task
+	mdp.Gamma = task.DiscountFactor
+	mdp.Transitions = make([][][]float64, numStates)
+	mdp.Rewards = make([][]float64, numStates)
+	for s := range mdp.Transitions {
+		mdp.Transitions[s] = make([][]float64, numActions)
+		mdp.Rewards[s] = make([]float64, numActions)
+		for a := range mdp.Transitions[s] {
+			mdp.Transitions[s][a] = make([]float64, numStates)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for lineno := 1; ; lineno++ {
+		line, rerr := r.ReadString('\n')
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) != 0 {
+			if err = parseMDPLine(mdp, fields, numStates, numActions); err != nil {
+				err = fmt.Errorf("%s:%d: %v", path, lineno, err)
+				return
+			}
+		}
+		if rerr == os.EOF {
+			break
+		}
+		if rerr != nil {
+			err = rerr
+			return
+		}
+	}
+	return
+}
+func parseMDPLine(mdp *tableMDP, fields []string, numStates, numActions uint64) (err os.Error) {
+	var want int
+	switch fields[0] {
+	case "t":
+		want = 5
+	case "r":
+		want = 4
+	default:
+		return fmt.Errorf("unknown line type %q", fields[0])
+	}
+	if len(fields) != want {
+		return fmt.Errorf("%q lines have %d fields", fields[0], want)
+	}
+	indices := make([]uint64, want-2)
+	for i := range indices {
+		if indices[i], err = strconv.Atoui64(fields[i+1]); err != nil {
+			return
+		}
+		limit := numStates
+		if i == 1 {
+			limit = numActions
+		}
+		if indices[i] >= limit {
+			return fmt.Errorf("index %d out of range", indices[i])
+		}
+	}
+	v, err := strconv.Atof64(fields[want-1])
+	if err != nil {
+		return
+	}
+	if fields[0] == "t" {
+		mdp.Transitions[indices[0]][indices[1]][indices[2]] = v
+	} else {
+		mdp.Rewards[indices[0]][indices[1]] = v
+	}
+	return
+}
+
+//the MDP comes with the task spec, so the planner is made in AgentInit
+type agent struct {
+	*uctmdp.Agent
+	cfg	Config
+	sink	telemetry.Sink
+}
+
+func (this *agent) AgentInit(taskString string) {
+	task, _ := rlglue.ParseTaskSpec(taskString)
+	if task.DiscountFactor == 1 {
+		task.DiscountFactor = 0.95
+	}
+	mdp, err := loadMDP(this.cfg.MDP, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	this.Agent = uctmdp.New(this.cfg.UCT, mdp)
+	this.Agent.Telemetry = this.sink
+	this.Agent.AgentInit(taskString)
+}
+
+func main() {
+	defer nicetrace.Print()
+	var config Config
+	config.UCT = uctmdp.ConfigDefault()
+	argcfg.LoadArgs(&config)
+	if err := config.UCT.UCT.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if config.MDP == "" {
+		fmt.Fprintf(os.Stderr, "no MDP file given\n")
+		os.Exit(1)
+	}
+	sink, err := telemetry.New(config.Telemetry, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	a := &agent{cfg: config, sink: sink}
+	if err := rlglue.LoadAgent(a); err != nil {
+		fmt.Printf("Error running uct: %v\n", err)
+	}
+}
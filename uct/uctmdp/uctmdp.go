@@ -0,0 +1,116 @@
+package uctmdp
+
+import (
+	"strings"
+	"strconv"
+	"go-glue.googlecode.com/hg/rlglue"
+	"go-glue.googlecode.com/hg/rltools/discrete"
+	"github.com/skelterjohn/rlalg/fsss/fsssmdp"
+	"github.com/skelterjohn/rlalg/plan"
+	"github.com/skelterjohn/rlalg/telemetry"
+	"github.com/skelterjohn/rlalg/uct"
+)
+
+type Config struct {
+	Depth		uint64
+	NumTrajectories	uint64
+	Budget		uint64
+	//nanoseconds per decision. If set, planning runs until it has passed and
+	//NumTrajectories and Budget are ignored.
+	Deadline	int64
+	UCT		uct.Config
+}
+
+func ConfigDefault() (cfg Config) {
+	cfg.Depth = 10
+	cfg.NumTrajectories = 100
+	cfg.Budget = 1000
+	cfg.Deadline = 0
+	cfg.UCT = uct.ConfigDefault()
+	return
+}
+
+//Agent plans with UCT in a known MDP, the way fsssmdp.Agent does with FSSS.
+type Agent struct {
+	cfg		Config
+	mdp		discrete.MDP
+	lastState	discrete.State
+	lastAction	discrete.Action
+	s		*uct.Searcher
+	mdpo		*fsssmdp.Oracle
+	Telemetry	telemetry.Sink
+	LastPlan	plan.Stats
+}
+
+func New(cfg Config, mdp discrete.MDP) (this *Agent) {
+	this = new(Agent)
+	this.cfg = cfg
+	this.mdp = mdp
+	this.mdpo = fsssmdp.NewOracle(this.mdp, 0)
+	this.s = uct.New()
+	this.s.Cfg = this.cfg.UCT
+	if this.s.Cfg.Gamma == 0 {
+		this.s.Cfg.Gamma = mdp.GetGamma()
+	}
+	this.s.NumActions = this.mdp.NumActions()
+	if this.s.Cfg.ValueRange == 0 && this.s.Cfg.Gamma < 1 {
+		task := this.mdp.GetTask()
+		this.s.Cfg.ValueRange = (task.Reward.Max - task.Reward.Min) / (1 - this.s.Cfg.Gamma)
+	}
+	if err := this.s.Cfg.Check(); err != nil {
+		panic(err.String())
+	}
+	this.Telemetry = telemetry.Nop{}
+	return
+}
+func (*Agent) AgentInit(taskString string) {
+}
+func (this *Agent) AgentStart(obs rlglue.Observation) (act rlglue.Action) {
+	this.lastState = discrete.State(this.mdp.GetTask().Obs.Ints.Index(obs.Ints()))
+	this.Plan()
+	act = rlglue.NewAction(this.mdp.GetTask().Act.Ints.Values(this.GetAction()), []float64{}, []byte{})
+	this.lastAction = discrete.Action(this.mdp.GetTask().Act.Ints.Index(act.Ints()))
+	return
+}
+func (this *Agent) AgentStep(reward float64, obs rlglue.Observation) (act rlglue.Action) {
+	this.lastState = discrete.State(this.mdp.GetTask().Obs.Ints.Index(obs.Ints()))
+	this.Plan()
+	act = rlglue.NewAction(this.mdp.GetTask().Act.Ints.Values(this.GetAction()), []float64{}, []byte{})
+	this.lastAction = discrete.Action(this.mdp.GetTask().Act.Ints.Index(act.Ints()))
+	return
+}
+func (this *Agent) AgentEnd(reward float64) {
+}
+func (this *Agent) AgentCleanup() {
+}
+func (this *Agent) AgentMessage(message string) string {
+	tokens := strings.Split(message, " ", -1)
+	if tokens[0] == "seed" {
+		seed, _ := strconv.Atoi64(tokens[1])
+		this.s.Seed(seed)
+	}
+	return ""
+}
+func (this *Agent) root() *uct.Node {
+	return this.s.GetNode(uct.DiscreteOracle{Oracle: this.mdpo})
+}
+func (this *Agent) GetAction() (action uint64) {
+	node := this.root()
+	action = this.s.GetAction(node)
+	this.Telemetry.Emit(telemetry.Event{Kind: telemetry.ActionChosen, Action: action, Q: this.s.GetQs(node)})
+	return
+}
+//Plan runs UCT from the current state. Nodes are memoized by state, so the
+//search from earlier steps carries over.
+func (this *Agent) Plan() {
+	this.s.Telemetry = this.Telemetry
+	this.mdpo = this.mdpo.Teleport(this.lastState)
+	var lim plan.Limits
+	if this.cfg.Deadline != 0 {
+		lim.Deadline = this.cfg.Deadline
+	} else {
+		lim.Trajectories = this.cfg.NumTrajectories
+		lim.Budget = this.cfg.Budget
+	}
+	this.LastPlan = this.s.Plan(this.root(), this.cfg.Depth, lim)
+}